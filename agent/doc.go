@@ -0,0 +1,4 @@
+// Package agent implements the non-learning players of m,n,k-games: humans
+// at the terminal, random, minimax and Monte Carlo tree search agents, and
+// peers over the network
+package agent
@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+
+	"mnkagent/mnk"
+)
+
+type HumanAgent struct {
+	id   int
+	Sign string
+}
+
+func NewHumanAgent(id int, sign string) (agent *HumanAgent) {
+	agent = new(HumanAgent)
+	agent.id = id
+	agent.Sign = sign
+	return
+}
+
+func (agent *HumanAgent) FetchMessage() string {
+	return ""
+}
+
+func (agent *HumanAgent) FetchMove(state mnk.State, pa []mnk.Action) (action mnk.Action, err error) {
+	fmt.Print("\n\033[2K\r")
+	fmt.Printf("%s > Your move (r to resign)? ", agent.Sign)
+
+	var input string
+	_, err = fmt.Scanln(&input)
+
+	fmt.Print("\r\033[F\033[F")
+
+	if err != nil {
+		return action, err
+	}
+
+	if input == "r" {
+		return action, mnk.ErrResign
+	}
+
+	pos, err := strconv.Atoi(input)
+	if err != nil {
+		// Let the environment reject it as any other invalid move
+		pos, err = 0, nil
+	}
+
+	// Cells are numbered row by row
+	m := len(state.(mnk.MNKState)[0])
+	return mnk.MNKAction{Y: (pos - 1) / m, X: (pos - 1) % m}, nil
+}
+
+func (agent *HumanAgent) GameOver(state mnk.State) {}
+
+func (agent *HumanAgent) GetSign() string {
+	return agent.Sign
+}
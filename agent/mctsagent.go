@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"mnkagent/mnk"
+)
+
+// mctsExploration is the UCT exploration constant
+const mctsExploration = math.Sqrt2
+
+// MCTSAgent chooses moves by Monte Carlo tree search with random rollouts
+type MCTSAgent struct {
+	id   int
+	Sign string
+
+	// Game definition
+	m, n, k int
+
+	// Number of simulations per move
+	Budget int
+
+	// Scratch environment and random generator used for simulations
+	env     *mnk.MNKBoard
+	board   mnk.MNKState
+	rng     *rand.Rand
+	message string
+}
+
+// mctsNode is a position reached by playing action as mover
+type mctsNode struct {
+	action   mnk.MNKAction
+	mover    int
+	result   int // Result of action: 0 goes on, -1 draw, otherwise the winner
+	parent   *mctsNode
+	children []*mctsNode
+	untried  []mnk.MNKAction
+	visits   float64
+	score    float64 // From the mover's point of view
+}
+
+func NewMCTSAgent(id int, sign string, m, n, k, budget int) (agent *MCTSAgent) {
+	agent = new(MCTSAgent)
+	agent.id = id
+	agent.Sign = sign
+
+	agent.m = m
+	agent.n = n
+	agent.k = k
+
+	agent.Budget = budget
+	agent.env, _ = mnk.NewMNKBoard(m, n, k) // Only ever given valid games
+	agent.rng = mnk.NewRand()
+	return
+}
+
+func (agent *MCTSAgent) FetchMessage() (message string) {
+	message = agent.message
+	agent.message = ""
+	return
+}
+
+func (agent *MCTSAgent) FetchMove(state mnk.State, possibleActions []mnk.Action) (mnk.Action, error) {
+	if len(possibleActions) == 0 {
+		return nil, errors.New("agent: no possible actions")
+	}
+
+	var s mnk.MNKState = state.(mnk.MNKState)
+	var root = &mctsNode{mover: 3 - agent.id, untried: s.EmptyCells()}
+
+	for i := 0; i < agent.Budget; i++ {
+		agent.board = s.Clone()
+		agent.env.SetState(agent.board)
+		node := root
+
+		// Selection
+		for len(node.untried) == 0 && len(node.children) > 0 {
+			node = node.selectChild()
+			agent.board[node.action.Y][node.action.X] = node.mover
+		}
+
+		// Expansion
+		if node.result == 0 && len(node.untried) > 0 {
+			j := agent.rng.Intn(len(node.untried))
+			a := node.untried[j]
+			node.untried[j] = node.untried[len(node.untried)-1]
+			node.untried = node.untried[:len(node.untried)-1]
+
+			child := &mctsNode{action: a, mover: 3 - node.mover, parent: node}
+			agent.board[a.Y][a.X] = child.mover
+			child.result = agent.env.EvaluateAction(child.mover, a)
+			if child.result == 1 {
+				child.result = child.mover
+			}
+			if child.result == 0 {
+				child.untried = agent.board.EmptyCells()
+			}
+			node.children = append(node.children, child)
+			node = child
+		}
+
+		// Simulation
+		result := node.result
+		if result == 0 {
+			result = agent.rollout(3 - node.mover)
+		}
+
+		// Backpropagation
+		for ; node != nil; node = node.parent {
+			node.visits++
+			if result == node.mover {
+				node.score++
+			} else if result == -1 {
+				node.score += 0.5
+			}
+		}
+	}
+
+	// Play the most visited move
+	var best *mctsNode
+	for _, c := range root.children {
+		if best == nil || c.visits > best.visits {
+			best = c
+		}
+	}
+	if best == nil {
+		return possibleActions[agent.rng.Intn(len(possibleActions))], nil
+	}
+
+	agent.message = fmt.Sprintf("MCTS win rate %.2f over %g visits",
+		best.score/best.visits, best.visits)
+	return best.action, nil
+}
+
+func (agent *MCTSAgent) GameOver(state mnk.State) {
+	agent.message = ""
+}
+
+func (agent *MCTSAgent) GetSign() string {
+	return agent.Sign
+}
+
+// rollout plays random moves from the scratch board and returns the winner's
+// id, or -1 for a draw
+func (agent *MCTSAgent) rollout(player int) int {
+	cells := agent.board.EmptyCells()
+	for len(cells) > 0 {
+		j := agent.rng.Intn(len(cells))
+		a := cells[j]
+		cells[j] = cells[len(cells)-1]
+		cells = cells[:len(cells)-1]
+
+		agent.board[a.Y][a.X] = player
+		switch agent.env.EvaluateAction(player, a) {
+		case 1:
+			return player
+		case -1:
+			return -1
+		}
+		player = 3 - player
+	}
+	return -1
+}
+
+// selectChild picks the child with the highest upper confidence bound
+func (node *mctsNode) selectChild() (best *mctsNode) {
+	var bestUCT = math.Inf(-1)
+	for _, c := range node.children {
+		uct := c.score/c.visits + mctsExploration*math.Sqrt(math.Log(node.visits)/c.visits)
+		if uct > bestUCT {
+			best, bestUCT = c, uct
+		}
+	}
+	return
+}
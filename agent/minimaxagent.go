@@ -0,0 +1,188 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"mnkagent/mnk"
+)
+
+// minimaxWin is the score of a won position, reduced by the moves it takes
+const minimaxWin = 1e6
+
+// minimaxNeighbourhood restricts the search on large boards to cells next to
+// existing marks
+const minimaxNeighbourhood = 49
+
+// MinimaxAgent searches the game tree to a fixed depth using alpha-beta
+// pruning and a line counting heuristic at the horizon
+type MinimaxAgent struct {
+	id   int
+	Sign string
+
+	// Game definition
+	m, n, k int
+
+	// Search depth in plies
+	Depth int
+
+	// Scratch environment used for the search
+	env     *mnk.MNKBoard
+	board   mnk.MNKState
+	message string
+
+	// Random generator breaking ties
+	rng *rand.Rand
+}
+
+func NewMinimaxAgent(id int, sign string, m, n, k, depth int) (agent *MinimaxAgent) {
+	agent = new(MinimaxAgent)
+	agent.id = id
+	agent.Sign = sign
+
+	agent.m = m
+	agent.n = n
+	agent.k = k
+
+	agent.Depth = depth
+	agent.env, _ = mnk.NewMNKBoard(m, n, k) // Only ever given valid games
+	agent.rng = mnk.NewRand()
+	return
+}
+
+func (agent *MinimaxAgent) FetchMessage() (message string) {
+	message = agent.message
+	agent.message = ""
+	return
+}
+
+func (agent *MinimaxAgent) FetchMove(state mnk.State, possibleActions []mnk.Action) (mnk.Action, error) {
+	if len(possibleActions) == 0 {
+		return nil, errors.New("agent: no possible actions")
+	}
+
+	agent.board = state.(mnk.MNKState).Clone()
+	agent.env.SetState(agent.board)
+
+	var best []mnk.MNKAction
+	var bestScore = math.Inf(-1)
+	for _, a := range agent.candidates() {
+		score := -agent.negamax(a, agent.id, agent.Depth-1, math.Inf(-1), math.Inf(1))
+		if score > bestScore {
+			best, bestScore = []mnk.MNKAction{a}, score
+		} else if score == bestScore {
+			best = append(best, a)
+		}
+	}
+
+	agent.message = fmt.Sprintf("Minimax score %g", bestScore)
+
+	// Break ties randomly so that games between the same agents vary
+	return best[agent.rng.Intn(len(best))], nil
+}
+
+func (agent *MinimaxAgent) GameOver(state mnk.State) {
+	agent.message = ""
+}
+
+func (agent *MinimaxAgent) GetSign() string {
+	return agent.Sign
+}
+
+// negamax plays the action for player and returns the score from the point of
+// view of the player to move next
+func (agent *MinimaxAgent) negamax(a mnk.MNKAction, player, depth int, alpha, beta float64) float64 {
+	b := agent.board
+	b[a.Y][a.X] = player
+	defer func() { b[a.Y][a.X] = 0 }()
+
+	switch agent.env.EvaluateAction(player, a) {
+	case 1: // Player won, so the opponent lost; prefer quick wins
+		return -(minimaxWin + float64(depth))
+	case -1: // Draw
+		return 0
+	}
+
+	opponent := 3 - player
+	if depth <= 0 {
+		return lineScore(agent.board, agent.k, opponent) - lineScore(agent.board, agent.k, player)
+	}
+
+	var best = math.Inf(-1)
+	for _, next := range agent.candidates() {
+		score := -agent.negamax(next, opponent, depth-1, -beta, -alpha)
+		if score > best {
+			best = score
+		}
+		if best > alpha {
+			alpha = best
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return best
+}
+
+// candidates returns the empty cells worth searching
+func (agent *MinimaxAgent) candidates() (a []mnk.MNKAction) {
+	b := agent.board
+	near := agent.m*agent.n > minimaxNeighbourhood
+	for i := range b {
+		for j := range b[i] {
+			if b[i][j] == 0 && (!near || occupiedAround(b, i, j)) {
+				a = append(a, mnk.MNKAction{Y: i, X: j})
+			}
+		}
+	}
+
+	// Nothing around on an empty board; start in the middle
+	if len(a) == 0 && near {
+		a = append(a, mnk.MNKAction{Y: agent.n / 2, X: agent.m / 2})
+	}
+	return
+}
+
+// occupiedAround reports whether any cell next to i,j is marked
+func occupiedAround(b mnk.MNKState, i, j int) bool {
+	for y := i - 1; y <= i+1; y++ {
+		for x := j - 1; x <= j+1; x++ {
+			if y >= 0 && y < len(b) && x >= 0 && x < len(b[y]) && b[y][x] != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lineScore sums, over every window of k cells that holds no opponent marks,
+// the square of the number of player's marks in it
+func lineScore(b mnk.MNKState, k, player int) (score float64) {
+	var directions = [4][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+
+	for i := range b {
+		for j := range b[i] {
+			for _, d := range directions {
+				ei, ej := i+d[0]*(k-1), j+d[1]*(k-1)
+				if ei < 0 || ei >= len(b) || ej < 0 || ej >= len(b[i]) {
+					continue
+				}
+
+				c := 0
+				for s := 0; s < k; s++ {
+					v := b[i+d[0]*s][j+d[1]*s]
+					if v == player {
+						c++
+					} else if v != 0 {
+						c = 0
+						break
+					}
+				}
+				score += float64(c * c)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"errors"
+	"math/rand"
+
+	"mnkagent/mnk"
+)
+
+type RandomAgent struct {
+	id   int
+	Sign string
+
+	rng *rand.Rand
+}
+
+func NewRandomAgent(id int, sign string) (agent *RandomAgent) {
+	agent = new(RandomAgent)
+	agent.id = id
+	agent.Sign = sign
+	agent.rng = mnk.NewRand()
+	return
+}
+
+func (agent *RandomAgent) FetchMessage() string {
+	return ""
+}
+
+func (agent *RandomAgent) FetchMove(state mnk.State, possibleActions []mnk.Action) (mnk.Action, error) {
+	if len(possibleActions) == 0 {
+		return nil, errors.New("agent: no possible actions")
+	}
+	return possibleActions[agent.rng.Intn(len(possibleActions))], nil
+}
+
+func (agent *RandomAgent) GameOver(state mnk.State) {}
+
+func (agent *RandomAgent) GetSign() string {
+	return agent.Sign
+}
@@ -0,0 +1,314 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+
+	"mnkagent/mnk"
+)
+
+// remoteProtocolVersion is bumped whenever the wire protocol changes
+const remoteProtocolVersion = 1
+
+// remoteMaxHandshake limits the size of the greeting messages
+const remoteMaxHandshake = 4096
+
+// Limits on the game definitions accepted from a host
+const (
+	remoteMaxDimension = 100
+	remoteMaxRounds    = 1000000
+)
+
+// ErrDisconnected is returned when the remote peer went away
+var ErrDisconnected = errors.New("remote: peer disconnected")
+
+// remoteMessage is a single line of the JSON wire protocol
+type remoteMessage struct {
+	Type    string `json:"type"` // hello|ready|move|gameover|resign|bye
+	Version int    `json:"version,omitempty"`
+	M       int    `json:"m,omitempty"`
+	N       int    `json:"n,omitempty"`
+	K       int    `json:"k,omitempty"`
+	Rounds  int    `json:"rounds,omitempty"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+}
+
+// RemoteAgent plays the moves of a peer connected over TCP. Local moves are
+// derived from the state it is handed and sent to the peer.
+type RemoteAgent struct {
+	id   int
+	Sign string
+
+	// Game definition
+	m, n, k int
+
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+	err  error
+
+	// Latest state known to both peers and the move that led to it
+	known    mnk.MNKState
+	last     mnk.MNKAction
+	lastBy   int
+	resigned bool
+
+	// Scratch environment used to tell finished games from resignations
+	env *mnk.MNKBoard
+}
+
+func NewRemoteAgent(id int, sign string, m, n, k int, conn net.Conn) (agent *RemoteAgent) {
+	agent = new(RemoteAgent)
+	agent.id = id
+	agent.Sign = sign
+
+	agent.m = m
+	agent.n = n
+	agent.k = k
+
+	agent.conn = conn
+	agent.enc = json.NewEncoder(conn)
+	agent.dec = json.NewDecoder(bufio.NewReader(conn))
+
+	agent.env, _ = mnk.NewMNKBoard(m, n, k) // Only ever given valid games
+	return
+}
+
+// HostRemote waits for a peer on the given address and proposes the game
+func HostRemote(addr string, m, n, k, rounds int) (net.Conn, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+
+	fmt.Printf("Waiting for a peer on %s...\n", ln.Addr())
+	return acceptRemote(ln, m, n, k, rounds)
+}
+
+// acceptRemote accepts a single peer and negotiates the game definition
+func acceptRemote(ln net.Listener, m, n, k, rounds int) (net.Conn, error) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.NewEncoder(conn).Encode(remoteMessage{
+		Type:    "hello",
+		Version: remoteProtocolVersion,
+		M:       m,
+		N:       n,
+		K:       k,
+		Rounds:  rounds,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	msg, err := readHandshake(conn)
+	if err != nil {
+		conn.Close()
+		return nil, ErrDisconnected
+	}
+	if msg.Type != "ready" {
+		conn.Close()
+		return nil, fmt.Errorf("remote: peer declined the game (%s)", msg.Type)
+	}
+
+	return conn, nil
+}
+
+// JoinRemote connects to a host and accepts its game definition
+func JoinRemote(addr string) (conn net.Conn, m, n, k, rounds int, err error) {
+	conn, err = net.Dial("tcp", addr)
+	if err != nil {
+		return
+	}
+
+	msg, err := readHandshake(conn)
+	if err != nil {
+		conn.Close()
+		err = ErrDisconnected
+		return
+	}
+
+	if msg.Type != "hello" || msg.Version != remoteProtocolVersion {
+		json.NewEncoder(conn).Encode(remoteMessage{Type: "bye"})
+		conn.Close()
+		err = fmt.Errorf("remote: unsupported greeting %q (version %d)",
+			msg.Type, msg.Version)
+		return
+	}
+
+	if !inRange(msg.M, remoteMaxDimension) || !inRange(msg.N, remoteMaxDimension) ||
+		!inRange(msg.K, remoteMaxDimension) || !inRange(msg.Rounds, remoteMaxRounds) {
+		json.NewEncoder(conn).Encode(remoteMessage{Type: "bye"})
+		conn.Close()
+		err = fmt.Errorf("remote: unsupported game %d,%d,%d of %d rounds",
+			msg.M, msg.N, msg.K, msg.Rounds)
+		return
+	}
+
+	if err = json.NewEncoder(conn).Encode(remoteMessage{Type: "ready"}); err != nil {
+		conn.Close()
+		return
+	}
+
+	return conn, msg.M, msg.N, msg.K, msg.Rounds, nil
+}
+
+// inRange reports whether v is between 1 and limit
+func inRange(v, limit int) bool {
+	return v >= 1 && v <= limit
+}
+
+// readHandshake reads a single message without buffering past its end, so
+// that the agent's decoder sees everything that follows
+func readHandshake(conn net.Conn) (msg remoteMessage, err error) {
+	var line []byte
+	var c = make([]byte, 1)
+	for c[0] != '\n' {
+		if _, err = conn.Read(c); err != nil {
+			return
+		}
+		line = append(line, c[0])
+		if len(line) > remoteMaxHandshake {
+			return msg, errors.New("remote: handshake too long")
+		}
+	}
+
+	err = json.Unmarshal(line, &msg)
+	return
+}
+
+func (agent *RemoteAgent) FetchMessage() string {
+	return ""
+}
+
+func (agent *RemoteAgent) FetchMove(state mnk.State, possibleActions []mnk.Action) (mnk.Action, error) {
+	var s mnk.MNKState = state.(mnk.MNKState)
+
+	// Let the peer know about our latest move
+	agent.sync(s)
+
+	for agent.err == nil {
+		var msg remoteMessage
+		if err := agent.dec.Decode(&msg); err != nil {
+			agent.err = ErrDisconnected
+			break
+		}
+
+		switch msg.Type {
+		case "move":
+			a := mnk.MNKAction{X: msg.X, Y: msg.Y}
+			if a.X < 0 || a.X >= agent.m || a.Y < 0 || a.Y >= agent.n {
+				agent.refuse(errors.New("remote: move out of range"))
+				break
+			}
+			if !available(a, possibleActions) {
+				agent.refuse(fmt.Errorf("remote: move to %d,%d is not available", a.X, a.Y))
+				break
+			}
+
+			agent.known = s.Clone()
+			agent.known[a.Y][a.X] = agent.id
+			agent.last, agent.lastBy = a, agent.id
+			return a, nil
+
+		case "gameover":
+			// The peer finished the previous round; nothing to do
+
+		case "resign":
+			agent.resigned = true
+			return nil, mnk.ErrResign
+
+		case "bye":
+			agent.err = ErrDisconnected
+
+		default:
+			agent.err = fmt.Errorf("remote: unexpected message %q", msg.Type)
+		}
+	}
+
+	return nil, agent.err
+}
+
+func (agent *RemoteAgent) GameOver(state mnk.State) {
+	var s mnk.MNKState = state.(mnk.MNKState)
+
+	// Send the final move, or resign if the game did not actually end
+	agent.sync(s)
+	if !agent.resigned && !agent.finished(s) {
+		agent.send(remoteMessage{Type: "resign"})
+	}
+	agent.send(remoteMessage{Type: "gameover"})
+
+	// Restart for the next episode
+	agent.known = nil
+	agent.lastBy = 0
+	agent.resigned = false
+}
+
+func (agent *RemoteAgent) GetSign() string {
+	return agent.Sign
+}
+
+// Close says goodbye to the peer and closes the connection
+func (agent *RemoteAgent) Close() error {
+	agent.send(remoteMessage{Type: "bye"})
+	return agent.conn.Close()
+}
+
+// refuse ends the game over an invalid move of the peer, which is told so
+func (agent *RemoteAgent) refuse(err error) {
+	agent.send(remoteMessage{Type: "bye"})
+	agent.err = err
+}
+
+// available reports whether a is one of the possible actions
+func available(a mnk.MNKAction, possibleActions []mnk.Action) bool {
+	for _, p := range possibleActions {
+		if p.GetParams() == a {
+			return true
+		}
+	}
+	return false
+}
+
+// sync sends the local moves made since the last known state
+func (agent *RemoteAgent) sync(s mnk.MNKState) {
+	for i := range s {
+		for j := range s[i] {
+			if s[i][j] == 0 || (agent.known != nil && agent.known[i][j] != 0) {
+				continue
+			}
+
+			agent.last, agent.lastBy = mnk.MNKAction{X: j, Y: i}, s[i][j]
+			agent.send(remoteMessage{Type: "move", X: j, Y: i})
+		}
+	}
+	agent.known = s.Clone()
+}
+
+// finished reports whether the last move ended the game
+func (agent *RemoteAgent) finished(s mnk.MNKState) bool {
+	if agent.lastBy == 0 {
+		return false
+	}
+	agent.env.SetState(s)
+	return agent.env.EvaluateAction(agent.lastBy, agent.last) != 0
+}
+
+func (agent *RemoteAgent) send(msg remoteMessage) {
+	if agent.err != nil {
+		return
+	}
+	if err := agent.enc.Encode(msg); err != nil {
+		agent.err = ErrDisconnected
+	}
+}
@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"net"
+	"testing"
+
+	"mnkagent/mnk"
+)
+
+// remotePair connects a host and a joining RemoteAgent over loopback
+func remotePair(t *testing.T) (host, guest *RemoteAgent) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := acceptRemote(ln, 3, 3, 3, 2)
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- conn
+	}()
+
+	conn, m, n, k, rounds, err := JoinRemote(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != 3 || n != 3 || k != 3 || rounds != 2 {
+		t.Fatalf("JoinRemote(): Expected 3,3,3 and 2 rounds, actual %d,%d,%d and %d",
+			m, n, k, rounds)
+	}
+
+	hostConn := <-accepted
+	if hostConn == nil {
+		t.FailNow()
+	}
+
+	// The host plays X locally, so its remote agent is O and vice versa
+	return NewRemoteAgent(2, "O", 3, 3, 3, hostConn), NewRemoteAgent(1, "X", 3, 3, 3, conn)
+}
+
+func TestRemoteAgentMoves(t *testing.T) {
+	host, guest := remotePair(t)
+	defer host.Close()
+	defer guest.Close()
+
+	// X plays 1,1 on the host, which the guest receives
+	var state = mnk.MNKState{{0, 0, 0}, {0, 1, 0}, {0, 0, 0}}
+	received := make(chan mnk.Action)
+	go func() {
+		a, err := host.FetchMove(state.Clone(), emptyCells(state))
+		if err != nil {
+			t.Error(err)
+		}
+		received <- a
+	}()
+
+	empty := mnk.MNKState{{0, 0, 0}, {0, 0, 0}, {0, 0, 0}}
+	a, err := guest.FetchMove(empty, emptyCells(empty))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != (mnk.MNKAction{X: 1, Y: 1}) {
+		t.Errorf("FetchMove(): Expected %v, actual %v", mnk.MNKAction{X: 1, Y: 1}, a)
+	}
+
+	// O replies 0,2 on the guest, which the host receives
+	state[2][0] = 2
+	go guest.FetchMove(state.Clone(), emptyCells(state))
+
+	if a = <-received; a != (mnk.MNKAction{X: 0, Y: 2}) {
+		t.Errorf("FetchMove(): Expected %v, actual %v", mnk.MNKAction{X: 0, Y: 2}, a)
+	}
+}
+
+func TestRemoteAgentOccupiedCell(t *testing.T) {
+	host, guest := remotePair(t)
+	defer host.Close()
+	defer guest.Close()
+
+	// A broken host plays X on 1,1, where the guest knows O already
+	refused := make(chan error)
+	go func() {
+		_, err := host.FetchMove(mnk.MNKState{{0, 0, 0}, {0, 1, 0}, {0, 0, 0}}, nil)
+		refused <- err
+	}()
+
+	state := mnk.MNKState{{0, 0, 0}, {0, 2, 0}, {0, 0, 0}}
+	guest.known = state.Clone()
+	if _, err := guest.FetchMove(state, emptyCells(state)); err == nil {
+		t.Error("FetchMove(): Expected an error for a move to an occupied cell")
+	}
+
+	// The host is told rather than left waiting
+	if err := <-refused; err != ErrDisconnected {
+		t.Errorf("FetchMove(): Expected %v, actual %v", ErrDisconnected, err)
+	}
+}
+
+func TestRemoteAgentResign(t *testing.T) {
+	host, guest := remotePair(t)
+	defer host.Close()
+	defer guest.Close()
+
+	// The host's local player resigns before moving
+	go host.GameOver(mnk.MNKState{{0, 0, 0}, {0, 0, 0}, {0, 0, 0}})
+
+	_, err := guest.FetchMove(mnk.MNKState{{0, 0, 0}, {0, 0, 0}, {0, 0, 0}}, nil)
+	if err != mnk.ErrResign {
+		t.Errorf("FetchMove(): Expected %v, actual %v", mnk.ErrResign, err)
+	}
+}
+
+func TestRemoteAgentDisconnect(t *testing.T) {
+	host, guest := remotePair(t)
+	defer guest.Close()
+
+	host.conn.Close()
+
+	_, err := guest.FetchMove(mnk.MNKState{{0, 0, 0}, {0, 0, 0}, {0, 0, 0}}, nil)
+	if err != ErrDisconnected {
+		t.Errorf("FetchMove(): Expected %v, actual %v", ErrDisconnected, err)
+	}
+
+	// Further calls fail the same way instead of blocking
+	if _, err = guest.FetchMove(mnk.MNKState{{0, 0, 0}, {0, 0, 0}, {0, 0, 0}}, nil); err != ErrDisconnected {
+		t.Errorf("FetchMove(): Expected %v, actual %v", ErrDisconnected, err)
+	}
+}
+
+// emptyCells returns the actions available on s
+func emptyCells(s mnk.MNKState) (actions []mnk.Action) {
+	for i := range s {
+		for j := range s[i] {
+			if s[i][j] == 0 {
+				actions = append(actions, mnk.MNKAction{X: j, Y: i})
+			}
+		}
+	}
+	return
+}
+
+func TestJoinRemoteRejectsInvalidGames(t *testing.T) {
+	for _, hello := range []string{
+		`{"type":"hello","version":1,"m":-1,"n":3,"k":3,"rounds":1}`,
+		`{"type":"hello","version":1,"m":3,"n":1000000000,"k":3,"rounds":1}`,
+		`{"type":"hello","version":1,"m":3,"n":3,"k":3,"rounds":0}`,
+	} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reply := make(chan string)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				reply <- ""
+				return
+			}
+			defer conn.Close()
+			conn.Write([]byte(hello + "\n"))
+			msg, _ := readHandshake(conn)
+			reply <- msg.Type
+		}()
+
+		conn, _, _, _, _, err := JoinRemote(ln.Addr().String())
+		if err == nil {
+			conn.Close()
+			t.Errorf("JoinRemote(): Expected an error for %s", hello)
+		}
+		if r := <-reply; r != "bye" {
+			t.Errorf("JoinRemote(): Expected a bye for %s, actual %q", hello, r)
+		}
+		ln.Close()
+	}
+}
@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"slices"
+	"testing"
+
+	"mnkagent/mnk"
+)
+
+// seededGame plays a game between MCTS and random agents and returns its moves
+func seededGame(t *testing.T, seed int64) (moves []mnk.MNKAction) {
+	mnk.Seed(seed)
+	b, _ := mnk.NewMNKBoard(4, 4, 3)
+	agents := [3]mnk.Agent{nil, NewMCTSAgent(1, "X", 4, 4, 3, 50), NewRandomAgent(2, "O")}
+
+	for turn := 1; ; turn = 3 - turn {
+		action, err := agents[turn].FetchMove(b.GetState(), b.GetPotentialActions(turn))
+		if err != nil {
+			t.Fatalf("FetchMove(): Unexpected error %v", err)
+		}
+		b.Act(turn, action)
+		moves = append(moves, action.GetParams().(mnk.MNKAction))
+		if b.EvaluateAction(turn, action) != 0 {
+			return
+		}
+	}
+}
+
+func TestSeedReproducesGames(t *testing.T) {
+	a, b := seededGame(t, 7), seededGame(t, 7)
+	if !slices.Equal(a, b) {
+		t.Errorf("Seed(): Expected the same game for the same seed, actual %v and %v", a, b)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"mnkagent/agent"
+	"mnkagent/mnk"
+	"mnkagent/rl"
+	"mnkagent/train"
+)
+
+// Models loaded for "rl:path" and "nn:path" agents, shared between their
+// instances
+var (
+	specModels   = make(map[string]*rl.RLAgentKnowledge)
+	specNetworks = make(map[string]*rl.NNModel)
+	specModelsMu sync.Mutex
+)
+
+// newAgent constructs the agent described by spec for an m,n,k game; plain
+// "rl" and "nn" agents learn into the models. Specs are "human", "random", "rl", "rl:model-file", "nn", "nn:network-file",
+// "minimax:depth" and "mcts:budget".
+func (md *models) newAgent(spec string, id int, sign string, m, n, k int) (mnk.Agent, error) {
+	name, param, _ := strings.Cut(spec, ":")
+
+	switch name {
+	case "human":
+		return agent.NewHumanAgent(id, sign), nil
+
+	case "random":
+		return agent.NewRandomAgent(id, sign), nil
+
+	case "rl":
+		if param == "" {
+			return md.newRLAgent(id, sign, m, n, k, !rlNoLearn), nil
+		}
+
+		// A frozen, greedy agent playing by the given model
+		kw, err := loadSpecModel(param)
+		if err != nil {
+			return nil, err
+		}
+		agent := rl.NewRLAgent(id, sign, m, n, k, kw, false)
+		agent.Freeze()
+		return agent, nil
+
+	case "nn":
+		if param == "" {
+			if md.nn == nil {
+				return nil, fmt.Errorf("agent: no network in use, see -rl-nn")
+			}
+			return md.newNNAgent(id, sign, m, n, k, !rlNoLearn), nil
+		}
+
+		// A frozen, greedy agent playing by the given network
+		model, err := loadSpecNetwork(param)
+		if err != nil {
+			return nil, err
+		}
+		if model.M != m || model.N != n {
+			return nil, fmt.Errorf("agent: network %q was trained on a %d,%d board",
+				param, model.M, model.N)
+		}
+		agent := rl.NewNNAgent(id, sign, m, n, k, model, false)
+		agent.Freeze()
+		return agent, nil
+
+	case "minimax":
+		depth, err := strconv.Atoi(param)
+		if err != nil || depth < 1 {
+			return nil, fmt.Errorf("agent: invalid minimax depth %q", param)
+		}
+		return agent.NewMinimaxAgent(id, sign, m, n, k, depth), nil
+
+	case "mcts":
+		budget, err := strconv.Atoi(param)
+		if err != nil || budget < 1 {
+			return nil, fmt.Errorf("agent: invalid MCTS budget %q", param)
+		}
+		return agent.NewMCTSAgent(id, sign, m, n, k, budget), nil
+	}
+
+	return nil, fmt.Errorf("agent: unknown agent %q", spec)
+}
+
+// factory returns a factory of the agents of spec for the current game
+func (md *models) factory(spec string) train.Factory {
+	return func(id int) (mnk.Agent, error) {
+		return md.newAgent(spec, id, signs[id], m, n, k)
+	}
+}
+
+// loadSpecModel loads the model at path once
+func loadSpecModel(path string) (*rl.RLAgentKnowledge, error) {
+	specModelsMu.Lock()
+	defer specModelsMu.Unlock()
+
+	if kw, ok := specModels[path]; ok {
+		return kw, nil
+	}
+
+	kw := new(rl.RLAgentKnowledge)
+	if !kw.LoadFromFile(path) {
+		return nil, fmt.Errorf("agent: could not load model %q", path)
+	}
+	specModels[path] = kw
+	return kw, nil
+}
+
+// loadSpecNetwork loads the network at path once
+func loadSpecNetwork(path string) (*rl.NNModel, error) {
+	specModelsMu.Lock()
+	defer specModelsMu.Unlock()
+
+	if model, ok := specNetworks[path]; ok {
+		return model, nil
+	}
+
+	model := new(rl.NNModel)
+	if !model.LoadFromFile(path) {
+		return nil, fmt.Errorf("agent: could not load network %q", path)
+	}
+	specNetworks[path] = model
+	return model, nil
+}
@@ -0,0 +1,620 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"mnkagent/game"
+	"mnkagent/mnk"
+	"mnkagent/tournament"
+)
+
+// command is a subcommand of mnkagent with flags of its own
+type command struct {
+	name    string
+	args    string // Positional arguments of the usage line
+	summary string
+	flags   func(fs *flag.FlagSet)
+	run     func(fs *flag.FlagSet) error
+
+	// Keep the output clean for redirection
+	quiet bool
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "play", summary: "Play games against an agent, or over the network",
+			flags: playFlags, run: runPlay},
+		{name: "train", summary: "Train the RL model by self-play",
+			flags: trainFlags, run: runTrain},
+		{name: "match", args: "agent agent...",
+			summary: "Play a tournament between agents and rate them",
+			flags:   matchFlags, run: runMatch},
+		{name: "model", args: "command [flags] [args]",
+			summary: "Inspect, export, import, merge or prune the RL model " +
+				"(status|histogram|top|lookup|depth|coverage|export|import|merge|prune|diff)",
+			flags: modelFlags, run: runModel, quiet: true},
+		{name: "replay", args: "file", summary: "Show recorded games",
+			flags: replayFlags, run: runReplay, quiet: true},
+		{name: "serve", summary: "Serve games over HTTP",
+			flags: serveFlags, run: runServe},
+		{name: "sweep", summary: "Train and rank a grid of hyperparameters",
+			flags: sweepCommandFlags, run: runSweep},
+		{name: "help", args: "[command]", summary: "Show the help of a command",
+			run: runHelp, quiet: true},
+	}
+}
+
+func main() {
+	// Play is the default command
+	name, args := "play", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	fs := cmd.flagSet(flag.ExitOnError)
+	fs.Parse(args)
+
+	if err := setup(cmd, fs); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := cmd.run(fs); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// findCommand returns the command of the given name, or nil
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// flagSet returns a flag set of the command's flags
+func (cmd *command) flagSet(handling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, handling)
+	if cmd.flags != nil {
+		cmd.flags(fs)
+		configFlags(fs)
+	}
+	fs.Usage = func() { cmd.usage(fs.Output(), fs) }
+	return fs
+}
+
+// usage prints the help text of the command
+func (cmd *command) usage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: mnkagent %s", cmd.name)
+	if cmd.flags != nil {
+		fmt.Fprint(w, " [flags]")
+	}
+	if cmd.args != "" {
+		fmt.Fprint(w, " "+cmd.args)
+	}
+	fmt.Fprintf(w, "\n\n%s\n", cmd.summary)
+
+	if cmd.flags != nil {
+		fmt.Fprint(w, "\nFlags:\n")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
+
+// usage prints the list of commands
+func usage(w io.Writer) {
+	fmt.Fprint(w, "Usage: mnkagent [command] [flags]\n\nCommands:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprint(w, "\nThe default command is play. "+
+		"Run mnkagent help <command> for the flags of a command.\n")
+}
+
+// setup applies the config file and the settings every command shares
+func setup(cmd *command, fs *flag.FlagSet) error {
+	if cmd.flags == nil {
+		return nil
+	}
+
+	if configFile != "" {
+		if err := loadConfig(fs, configFile); err != nil {
+			return err
+		}
+	}
+	if fs.Lookup(playerFlag(1, "alpha")) != nil {
+		if err := resolvePlayerParams(fs); err != nil {
+			return err
+		}
+	}
+	// Pick a seed that the config dump can reproduce the run with
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	mnk.Seed(seed)
+
+	if configDump != "" {
+		if err := writeConfig(fs, configDump); err != nil {
+			return err
+		}
+	}
+
+	if !cmd.quiet {
+		fmt.Println("MNK Agent v2")
+	}
+
+	if gomoku {
+		m = 19
+		n = 19
+		k = 5
+	}
+
+	// Validate the game definition
+	_, err := mnk.NewMNKBoard(m, n, k)
+	return err
+}
+
+// noArgs fails on positional arguments of commands that take none
+func noArgs(fs *flag.FlagSet) error {
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%s: unexpected argument %q", fs.Name(), fs.Arg(0))
+	}
+	return nil
+}
+
+// Flag groups, registered with the current values as defaults
+
+func configFlags(fs *flag.FlagSet) {
+	fs.StringVar(&configFile, "config", configFile, "JSON file of flag "+
+		"settings, e.g. {\"m\": 4, \"p1\": {\"alpha\": 0.1}}; settings in "+
+		"an object named after a command apply to that command only, and "+
+		"the command line takes precedence")
+	fs.StringVar(&configDump, "config-dump", configDump, "Write the "+
+		"effective settings to the given file at startup, or to stdout for -")
+}
+
+func gameFlags(fs *flag.FlagSet) {
+	fs.IntVar(&m, "m", m, "Board dimention across the horizontal (x) axis")
+	fs.IntVar(&n, "n", n, "Board dimention across the vertical (y) axis")
+	fs.IntVar(&k, "k", k, "Number of marks in a row")
+	fs.BoolVar(&gomoku, "gomoku", gomoku, "Shortcut for a 19,19,5 game "+
+		"(overrides m, n and k)")
+	fs.Int64Var(&seed, "seed", seed, "Seed of every random choice of the "+
+		"run; the same seed reproduces the same games (default random)")
+}
+
+func modelFileFlag(fs *flag.FlagSet) {
+	fs.StringVar(&rlModelFile, "rl-model", rlModelFile, "RL trained model "+
+		"file location")
+}
+
+func storeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&rlStore, "rl-store", rlStore, "Where the values of the "+
+		"model are kept, converting it if needed: gob in the model file, or "+
+		"log in an append-only store next to it (gob|log) (default gob)")
+	fs.IntVar(&rlMemoryCap, "rl-memory-cap", rlMemoryCap, "Values of a log "+
+		"model kept in memory; the least recently updated spill to the store "+
+		"(default unbounded)")
+}
+
+// learnerFlags are the flags of the learning agents and their model
+func learnerFlags(fs *flag.FlagSet) {
+	gameFlags(fs)
+	modelFileFlag(fs)
+	storeFlags(fs)
+	registerPlayerFlags(fs)
+
+	fs.StringVar(&rlAlgo, "rl-algo", rlAlgo, "RL update rule of a new "+
+		"model, stored in the model (q|sarsa|expected-sarsa|double-q) "+
+		"(default q)")
+	fs.BoolVar(&rlAfterstates, "rl-afterstates", rlAfterstates, "Learn "+
+		"values of afterstates, shared between X and O, in a new model")
+	fs.StringVar(&rlAlphaSchedule, "rl-alpha-schedule", rlAlphaSchedule,
+		"Learning rate schedule over the model's iterations, stored in the "+
+			"model, e.g. linear:initial=0.2,final=0.01,steps=1e6 (constant|"+
+			"linear|exponential|inverse-time|step)")
+	fs.BoolVar(&rlAlphaCount, "rl-alpha-count", rlAlphaCount, "Decay the "+
+		"learning rate of each state from 1 with its updates, down to the "+
+		"scheduled rate; stored in the model")
+	fs.StringVar(&rlEpsilonSchedule, "rl-epsilon-schedule", rlEpsilonSchedule,
+		"Exploration schedule over the model's iterations, stored in the "+
+			"model, e.g. exponential:initial=0.25,rate=0.99999,final=0.01")
+	fs.StringVar(&rlExplore, "rl-exploration", rlExplore, "Exploration "+
+		"policy of the RL agent, stored in the model (epsilon-greedy|softmax|"+
+		"ucb|optimistic) (default epsilon-greedy)")
+	fs.StringVar(&rlTempSchedule, "rl-temperature-schedule", rlTempSchedule,
+		"Softmax temperature schedule over the model's iterations, stored in "+
+			"the model, e.g. exponential:initial=1,rate=0.9999,final=0.05 "+
+			"(default 1)")
+	fs.Float64Var(&rlUCBFactor, "rl-ucb-c", rlUCBFactor, "Weight of the "+
+		"UCB1 exploration bonus, stored in the model (default sqrt 2)")
+	fs.Float64Var(&rlOptimisticValue, "rl-optimistic-value", rlOptimisticValue,
+		"Initial value of unseen pairs under optimistic exploration, stored "+
+			"in the model (default 1)")
+	fs.Float64Var(&rlLambda, "rl-lambda", rlLambda, "Trace decay of "+
+		"TD(lambda) training of both players; 0 performs one-step updates")
+	fs.StringVar(&rlTraces, "rl-traces", rlTraces, "Eligibility traces of "+
+		"TD(lambda) training (replacing|accumulating)")
+	fs.BoolVar(&rlNN, "rl-nn", rlNN, "Use a neural network value function, "+
+		"stored next to the RL model with an .nn extension")
+	fs.StringVar(&rlNNHidden, "rl-nn-hidden", rlNNHidden, "Comma separated "+
+		"hidden layer sizes of a new network")
+	fs.StringVar(&rlNNOptimizer, "rl-nn-optimizer", rlNNOptimizer, "Optimizer "+
+		"of a new network (sgd|adam)")
+	fs.Float64Var(&rlNNLearningRate, "rl-nn-lr", rlNNLearningRate, "Learning "+
+		"rate of a new network")
+	fs.StringVar(&rlShaping, "rl-shaping", rlShaping, "Potential-based "+
+		"reward shaping terms of the RL agent, e.g. threat=0.05,block=0.05 "+
+		"for open runs of k-1 marks of the agent and of the opponent "+
+		"(default off)")
+}
+
+func metricsAddrFlag(fs *flag.FlagSet) {
+	fs.StringVar(&metricsAddr, "metrics-addr", metricsAddr, "Serve "+
+		"Prometheus metrics on /metrics and pprof on /debug/pprof/ at the "+
+		"given address, e.g. localhost:9090")
+}
+
+func recordFlag(fs *flag.FlagSet) {
+	fs.StringVar(&recordFile, "record", recordFile, "Append the games "+
+		"played to the given file, as JSON lines, for the replay command")
+}
+
+func playFlags(fs *flag.FlagSet) {
+	learnerFlags(fs)
+	recordFlag(fs)
+
+	fs.IntVar(&rounds, "rounds", rounds, "Number of rounds to play "+
+		"(default ask)")
+	fs.StringVar(&p1Spec, "p1", p1Spec, "Agent of player 1 (X) "+
+		"(human|random|rl|rl:model-file|nn|nn:network-file|"+
+		"minimax:depth|mcts:budget)")
+	fs.StringVar(&p2Spec, "p2", p2Spec, "Agent of player 2 (O) "+
+		"(default the learner of the model)")
+	fs.BoolVar(&rlNoLearn, "rl-no-learn", rlNoLearn, "Turn off learning "+
+		"for RL and don't save model to disk")
+
+	fs.StringVar(&hostAddr, "host", hostAddr, "Host a two-player game over "+
+		"TCP on the given address, e.g. :7777")
+	fs.StringVar(&joinAddr, "join", joinAddr, "Join a two-player game "+
+		"hosted on the given address")
+	fs.StringVar(&netAgent, "net-agent", netAgent, "Local player in host "+
+		"and join modes (human|random|rl|minimax:depth|mcts:budget)")
+}
+
+// trainingFlags are the flags of a training run, shared by train and sweep
+func trainingFlags(fs *flag.FlagSet) {
+	learnerFlags(fs)
+	metricsAddrFlag(fs)
+
+	fs.IntVar(&rounds, "rounds", rounds, "Number of training iterations")
+	fs.BoolVar(&noDisplay, "no-display", noDisplay, "Do not show the "+
+		"progress bar and stats")
+
+	fs.UintVar(&rlEvalEvery, "rl-eval-every", rlEvalEvery, "Evaluate the "+
+		"model against a baseline every n training iterations")
+	fs.IntVar(&rlEvalGames, "rl-eval-games", rlEvalGames, "Number of greedy "+
+		"games per evaluation")
+	fs.StringVar(&rlEvalOpponent, "rl-eval-opponent", rlEvalOpponent,
+		"Baseline agent for evaluations, e.g. random or minimax:2")
+	fs.StringVar(&rlEvalLog, "rl-eval-log", rlEvalLog, "CSV file "+
+		"evaluation results are appended to")
+
+	fs.IntVar(&rlReplaySize, "rl-replay-size", rlReplaySize, "Capacity of "+
+		"the experience replay buffer; 0 learns from each transition as it "+
+		"happens")
+	fs.IntVar(&rlReplayBatch, "rl-replay-batch", rlReplayBatch, "Transitions "+
+		"sampled from the replay buffer per update")
+	fs.BoolVar(&rlReplayPrioritized, "rl-replay-prioritized", rlReplayPrioritized,
+		"Sample transitions in proportion to their TD errors rather than "+
+			"uniformly")
+	fs.BoolVar(&rlReplayCheckpoint, "rl-replay-checkpoint", rlReplayCheckpoint,
+		"Store the replay buffer next to the RL model with a .replay extension")
+
+	fs.BoolVar(&rlLeague, "rl-league", rlLeague, "Train against a league "+
+		"of the current self, frozen snapshots of past selves and baselines "+
+		"instead of pure self-play")
+	fs.UintVar(&rlLeagueEvery, "rl-league-snapshot-every", rlLeagueEvery,
+		"Snapshot the model into the league every n training iterations")
+	fs.IntVar(&rlLeagueSize, "rl-league-size", rlLeagueSize, "Number of "+
+		"snapshots kept in the league, replacing the oldest")
+	fs.StringVar(&rlLeagueWeights, "rl-league-weights", rlLeagueWeights,
+		"Odds of league opponent kinds")
+	fs.StringVar(&rlLeagueBaselines, "rl-league-baselines", rlLeagueBaselines,
+		"Comma separated baseline agents of the league")
+
+	fs.StringVar(&metricsLog, "metrics-log", metricsLog, "Log training "+
+		"metrics to the given file, or to stdout instead of the progress bar "+
+		"for -")
+	fs.UintVar(&metricsEvery, "metrics-every", metricsEvery, "Training "+
+		"iterations per metrics record")
+	fs.StringVar(&metricsFormat, "metrics-format", metricsFormat, "Format of "+
+		"the metrics log (json|csv) (default the extension of the log, or "+
+		"json lines)")
+}
+
+func trainFlags(fs *flag.FlagSet) {
+	trainingFlags(fs)
+}
+
+func matchFlags(fs *flag.FlagSet) {
+	gameFlags(fs)
+	modelFileFlag(fs)
+	recordFlag(fs)
+
+	fs.StringVar(&tournamentFormat, "format", tournamentFormat, "Tournament "+
+		"schedule (roundrobin|swiss)")
+	fs.IntVar(&tournamentGames, "games", tournamentGames, "Games per "+
+		"pairing, alternating colors")
+	fs.IntVar(&tournamentRounds, "swiss-rounds", tournamentRounds, "Rounds "+
+		"of a swiss tournament (default log2 of the number of agents + 1)")
+	fs.StringVar(&ratingsFile, "ratings", ratingsFile, "Elo rating table "+
+		"location")
+}
+
+func modelFlags(fs *flag.FlagSet) {
+	gameFlags(fs)
+	modelFileFlag(fs)
+	storeFlags(fs)
+}
+
+// Flags of the replay command
+var (
+	replayNumber int
+	replayDelay  = 500 * time.Millisecond
+)
+
+func replayFlags(fs *flag.FlagSet) {
+	fs.IntVar(&replayNumber, "game", replayNumber, "Number of the game to show, "+
+		"starting at 1 (default all)")
+	fs.DurationVar(&replayDelay, "delay", replayDelay, "Pause between moves")
+}
+
+func serveFlags(fs *flag.FlagSet) {
+	gameFlags(fs)
+	modelFileFlag(fs)
+	metricsAddrFlag(fs)
+
+	fs.StringVar(&serveAddr, "addr", serveAddr, "Address to serve games on")
+	fs.IntVar(&serveMaxGames, "max-games", serveMaxGames, "Maximum number "+
+		"of concurrent games")
+}
+
+// Runners
+
+func runPlay(fs *flag.FlagSet) error {
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+	md, _, err := loadModels()
+	if err != nil {
+		return err
+	}
+	tm := newTrainingMonitor(md)
+
+	var recorder *game.Recorder
+	if recordFile != "" {
+		if recorder, err = game.NewRecorder(recordFile, seed); err != nil {
+			return err
+		}
+		defer recorder.Close()
+	}
+
+	if joinAddr != "" {
+		recorder.Seat("remote", netAgent)
+		log, err := join(md, tm, recorder, joinAddr)
+		if err != nil {
+			return err
+		}
+		printStats(log, nil)
+		return nil
+	}
+
+	if rounds <= 0 {
+		fmt.Printf("? > How many rounds shall we play? ")
+		if _, err := fmt.Scanln(&rounds); err != nil {
+			return fmt.Errorf("could not read the number of rounds: %v", err)
+		}
+	}
+
+	if hostAddr != "" {
+		recorder.Seat(netAgent, "remote")
+		log, err := host(md, tm, recorder, hostAddr, rounds)
+		if err != nil {
+			return err
+		}
+		printStats(log, nil)
+		return nil
+	}
+
+	fmt.Println("Great! Have fun.")
+
+	board, err := mnk.NewMNKBoard(m, n, k)
+	if err != nil {
+		return err
+	}
+	p1, err := md.newAgent(p1Spec, 1, X, m, n, k)
+	if err != nil {
+		return err
+	}
+	var p2 mnk.Agent
+	if p2Spec == "" {
+		// The learner of the model
+		p2 = md.newLearner(2, O, !rlNoLearn)
+		recorder.Seat(p1Spec, "rl")
+		if md.nn != nil {
+			recorder.Seat(p1Spec, "nn")
+		}
+	} else {
+		if p2, err = md.newAgent(p2Spec, 2, O, m, n, k); err != nil {
+			return err
+		}
+		recorder.Seat(p1Spec, p2Spec)
+	}
+
+	log := play(md, tm, recorder, board, rounds, p1, p2)
+	printStats(log, nil)
+	return nil
+}
+
+func runTrain(fs *flag.FlagSet) error {
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+	if rounds <= 0 {
+		return errors.New("train: -rounds is required")
+	}
+	md, _, err := loadModels()
+	if err != nil {
+		return err
+	}
+	tm := newTrainingMonitor(md)
+	if metricsAddr != "" {
+		if err := tm.serve(metricsAddr); err != nil {
+			return err
+		}
+	}
+
+	// Stop before the next game on SIGINT
+	sigint := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	signal.Notify(sigint, os.Interrupt)
+	defer signal.Stop(sigint)
+	go func() {
+		<-sigint
+		close(stop)
+		signal.Reset(os.Interrupt)
+	}()
+
+	// Start training loop
+	log := trainLearners(md, tm, uint(rounds), stop)
+	printStats(log, md.kw)
+	return nil
+}
+
+func runMatch(fs *flag.FlagSet) error {
+	md, _, err := loadModels()
+	if err != nil {
+		return err
+	}
+
+	var recorder *game.Recorder
+	if recordFile != "" {
+		if recorder, err = game.NewRecorder(recordFile, seed); err != nil {
+			return err
+		}
+		defer recorder.Close()
+	}
+
+	board, err := mnk.NewMNKBoard(m, n, k)
+	if err != nil {
+		return err
+	}
+	ratings, err := tournament.LoadRatings(ratingsFile)
+	if err != nil {
+		return err
+	}
+	t, err := tournament.New(board, fs.Args(), ratings, func(spec string, id int) (mnk.Agent, error) {
+		return md.newAgent(spec, id, signs[id], m, n, k)
+	})
+	if err != nil {
+		return err
+	}
+	t.Games = tournamentGames
+	t.Observer, t.Seated = recorder, recorder.Seat
+	t.Out = os.Stdout
+
+	switch tournamentFormat {
+	case "roundrobin":
+		err = t.RoundRobin()
+	case "swiss":
+		err = t.Swiss(tournamentRounds)
+	default:
+		err = fmt.Errorf("tournament: unknown format %q", tournamentFormat)
+	}
+	if err != nil {
+		return err
+	}
+	if err = recorder.Err(); err != nil {
+		return err
+	}
+
+	t.PrintCrosstable(os.Stdout)
+	return ratings.SaveToFile(ratingsFile)
+}
+
+func runModel(fs *flag.FlagSet) error {
+	md, loaded, err := loadModels()
+	if err != nil {
+		return err
+	}
+
+	// Model commands operate on every value
+	if err = md.kw.LoadAll(); err != nil {
+		return err
+	}
+
+	// A model may be imported into a new file
+	if !loaded && fs.Arg(0) != "import" {
+		return fmt.Errorf("model: could not read %s", rlModelFile)
+	}
+	return modelCommand(md, fs.Args())
+}
+
+func runReplay(fs *flag.FlagSet) error {
+	if fs.NArg() != 1 {
+		return errors.New("replay: takes a record file")
+	}
+	return replayGames(fs.Arg(0), replayNumber, replayDelay)
+}
+
+func runServe(fs *flag.FlagSet) error {
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+	md, _, err := loadModels()
+	if err != nil {
+		return err
+	}
+	if metricsAddr != "" {
+		if err := newTrainingMonitor(md).serve(metricsAddr); err != nil {
+			return err
+		}
+	}
+	return serve(md, serveAddr)
+}
+
+func runHelp(fs *flag.FlagSet) error {
+	if fs.NArg() == 0 {
+		usage(os.Stdout)
+		return nil
+	}
+
+	cmd := findCommand(fs.Arg(0))
+	if cmd == nil {
+		return fmt.Errorf("help: unknown command %q", fs.Arg(0))
+	}
+	cmd.usage(os.Stdout, cmd.flagSet(flag.ContinueOnError))
+	return nil
+}
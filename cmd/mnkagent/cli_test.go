@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	for _, cmd := range commands {
+		fs := cmd.flagSet(flag.ContinueOnError)
+		var buf bytes.Buffer
+		cmd.usage(&buf, fs)
+		if !strings.HasPrefix(buf.String(), "Usage: mnkagent "+cmd.name) {
+			t.Errorf("usage(): Expected the usage of %s, actual %q", cmd.name, buf.String())
+		}
+	}
+
+	// Registering flags keeps the current settings
+	m = 5
+	defer func() { m = 3 }()
+	fs := findCommand("play").flagSet(flag.ContinueOnError)
+	if err := fs.Parse([]string{"-rounds", "0", "-p2", ""}); err != nil || m != 5 {
+		t.Errorf("flagSet(): Expected m to stay 5, actual %d (%v)", m, err)
+	}
+
+	if findCommand("train").flagSet(flag.ContinueOnError).Lookup("p1") != nil {
+		t.Error("flagSet(): Expected no -p1 flag of the train command")
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"mnkagent/rl"
+)
+
+// playerParams are the hyperparameters of the learner in each seat
+var playerParams = [3]hyperparams{{}, defaultParams, defaultParams}
+
+// defaultParams are the hyperparameters of a player without flags
+var defaultParams = hyperparams{
+	LearningRate:      0.2,
+	DiscountFactor:    0.8,
+	ExplorationFactor: 0.25,
+}
+
+// hyperparams of a learning agent
+type hyperparams struct {
+	LearningRate      float64 // alpha
+	DiscountFactor    float64 // gamma
+	ExplorationFactor float64 // epsilon
+	Lambda            float64
+}
+
+// playerFlag returns the name of the flag of a player's setting, e.g. p1-alpha
+func playerFlag(id int, name string) string {
+	return fmt.Sprintf("p%d-%s", id, name)
+}
+
+// registerPlayerFlags adds the hyperparameter flags of each player to fs
+func registerPlayerFlags(fs *flag.FlagSet) {
+	for id := 1; id <= 2; id++ {
+		p := &playerParams[id]
+		fs.Float64Var(&p.LearningRate, playerFlag(id, "alpha"), p.LearningRate, fmt.Sprintf(
+			"Learning rate of player %d, unless scheduled", id))
+		fs.Float64Var(&p.DiscountFactor, playerFlag(id, "gamma"), p.DiscountFactor, fmt.Sprintf(
+			"Discount factor of player %d", id))
+		fs.Float64Var(&p.ExplorationFactor, playerFlag(id, "epsilon"), p.ExplorationFactor, fmt.Sprintf(
+			"Exploration rate of player %d, unless scheduled", id))
+		fs.Float64Var(&p.Lambda, playerFlag(id, "lambda"), p.Lambda, fmt.Sprintf(
+			"Trace decay of player %d (default -rl-lambda)", id))
+	}
+}
+
+// resolvePlayerParams validates the hyperparameters of the players, filling
+// in shared settings where no player setting was given
+func resolvePlayerParams(fs *flag.FlagSet) error {
+	set := setFlags(fs)
+	for id := 1; id <= 2; id++ {
+		p := &playerParams[id]
+		if !set[playerFlag(id, "lambda")] {
+			p.Lambda = rlLambda
+		}
+
+		if p.LearningRate <= 0 || p.LearningRate > 1 {
+			return fmt.Errorf("learning rate of player %d must be in (0, 1], not %g",
+				id, p.LearningRate)
+		}
+		for name, v := range map[string]float64{
+			"discount factor":  p.DiscountFactor,
+			"exploration rate": p.ExplorationFactor,
+			"lambda":           p.Lambda,
+		} {
+			if v < 0 || v > 1 {
+				return fmt.Errorf("%s of player %d must be between 0 and 1, not %g",
+					name, id, v)
+			}
+		}
+	}
+	return nil
+}
+
+// applyRL sets the hyperparameters of a tabular agent
+func (p hyperparams) applyRL(agent *rl.RLAgent) {
+	agent.LearningRate = p.LearningRate
+	agent.DiscountFactor = p.DiscountFactor
+	agent.ExplorationFactor = p.ExplorationFactor
+	agent.Lambda = p.Lambda
+}
+
+// applyNN sets the hyperparameters of a network agent; its learning rate is
+// the network's own
+func (p hyperparams) applyNN(agent *rl.NNAgent) {
+	agent.DiscountFactor = p.DiscountFactor
+	agent.ExplorationFactor = p.ExplorationFactor
+}
+
+// setFlags returns the flags given on the command line
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
+
+// loadConfig sets the flags of fs from the config file at path. Flags given
+// on the command line take precedence.
+func loadConfig(fs *flag.FlagSet, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return applyConfig(fs, file)
+}
+
+// applyConfig sets the flags of fs from a JSON config. Keys are flag names;
+// objects group flags under a common prefix, so {"p1": {"alpha": 0.1}} sets
+// -p1-alpha. An object named after a command holds settings of that command
+// only, which take precedence over the shared ones.
+func applyConfig(fs *flag.FlagSet, r io.Reader) error {
+	var config map[string]any
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&config); err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+
+	var scoped map[string]any
+	for _, cmd := range commands {
+		if v, ok := config[cmd.name].(map[string]any); ok {
+			if cmd.name == fs.Name() {
+				scoped = v
+			}
+			delete(config, cmd.name)
+		}
+	}
+
+	var values = make(map[string]string)
+	if err := flattenConfig("", config, values); err != nil {
+		return err
+	}
+	var own = make(map[string]string)
+	if err := flattenConfig("", scoped, own); err != nil {
+		return err
+	}
+	for name, v := range own {
+		values[name] = v
+	}
+
+	set := setFlags(fs)
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if fs.Lookup(name) == nil || name == "config" {
+			// Shared settings may be meant for other commands
+			if _, ok := own[name]; !ok && name != "config" && commandFlag(name) {
+				continue
+			}
+			return fmt.Errorf("config: unknown setting %q", name)
+		}
+		if set[name] {
+			continue
+		}
+		if err := fs.Set(name, values[name]); err != nil {
+			return fmt.Errorf("config: invalid %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// commandFlag reports whether any command has a flag of the given name
+func commandFlag(name string) bool {
+	for _, cmd := range commands {
+		if cmd.flags != nil && cmd.flagSet(flag.ContinueOnError).Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenConfig collects the settings of config under prefix into values
+func flattenConfig(prefix string, config map[string]any, values map[string]string) error {
+	for key, v := range config {
+		name := key
+		if prefix != "" {
+			name = prefix + "-" + key
+		}
+
+		switch v := v.(type) {
+		case map[string]any:
+			if err := flattenConfig(name, v, values); err != nil {
+				return err
+			}
+		case string:
+			values[name] = v
+		case json.Number:
+			values[name] = v.String()
+		case bool:
+			values[name] = fmt.Sprint(v)
+		default:
+			return fmt.Errorf("config: setting %q must be a string, number or boolean", name)
+		}
+	}
+	return nil
+}
+
+// dumpConfig writes the effective value of every flag as a config that
+// reproduces the run
+func dumpConfig(fs *flag.FlagSet, w io.Writer) error {
+	var config = make(map[string]any)
+	fs.VisitAll(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, "config") {
+			return
+		}
+		if g, ok := f.Value.(flag.Getter); ok {
+			config[f.Name] = g.Get()
+		} else {
+			config[f.Name] = f.Value.String()
+		}
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(config)
+}
+
+// writeConfig dumps the effective config to path, or to stdout for "-"
+func writeConfig(fs *flag.FlagSet, path string) error {
+	if path == "-" {
+		return dumpConfig(fs, os.Stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = dumpConfig(fs, file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestApplyConfig(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	size := fs.Int("m", 3, "")
+	spec := fs.String("p1", "human", "")
+	alpha := fs.Float64("p1-alpha", 0.2, "")
+	quiet := fs.Bool("no-display", false, "")
+	fs.Parse([]string{"-m", "5"})
+
+	config := `{"m": 4, "no-display": true, "p1": {"alpha": 0.1}}`
+	if err := applyConfig(fs, strings.NewReader(config)); err != nil {
+		t.Fatalf("applyConfig(): Unexpected error %v", err)
+	}
+	if *size != 5 || *alpha != 0.1 || !*quiet || *spec != "human" {
+		t.Errorf("applyConfig(): Expected m=5, p1-alpha=0.1 and no-display, actual %d, %g and %t",
+			*size, *alpha, *quiet)
+	}
+
+	// Settings applied from a config count as given
+	if set := setFlags(fs); !set["no-display"] || !set["p1-alpha"] || set["p1"] {
+		t.Errorf("applyConfig(): Expected no-display and p1-alpha to be set, actual %v", set)
+	}
+
+	// Unknown settings and invalid values are refused
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("no-display", false, "")
+	if err := applyConfig(fs, strings.NewReader(`{"p3": {"alpha": 1}}`)); err == nil {
+		t.Error("applyConfig(): Expected an error for an unknown setting")
+	}
+	if err := applyConfig(fs, strings.NewReader(`{"no-display": "maybe"}`)); err == nil {
+		t.Error("applyConfig(): Expected an error for an invalid value")
+	}
+}
+
+func TestDumpConfig(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("m", 3, "")
+	fs.Float64("p1-alpha", 0.2, "")
+	fs.String("config", "", "")
+	fs.Parse([]string{"-p1-alpha", "0.5"})
+
+	var buf bytes.Buffer
+	if err := dumpConfig(fs, &buf); err != nil {
+		t.Fatalf("dumpConfig(): Unexpected error %v", err)
+	}
+
+	var dumped map[string]any
+	json.Unmarshal(buf.Bytes(), &dumped)
+	if len(dumped) != 2 || dumped["m"] != 3.0 || dumped["p1-alpha"] != 0.5 {
+		t.Errorf("dumpConfig(): Expected m and p1-alpha only, actual %v", dumped)
+	}
+
+	// The dump reproduces the settings
+	other := flag.NewFlagSet("test", flag.ContinueOnError)
+	alpha := other.Float64("p1-alpha", 0.2, "")
+	other.Int("m", 3, "")
+	if err := applyConfig(other, &buf); err != nil || *alpha != 0.5 {
+		t.Errorf("applyConfig(): Expected the dump to set p1-alpha=0.5, actual %g (%v)", *alpha, err)
+	}
+}
+
+func TestApplyConfigCommands(t *testing.T) {
+	fs := flag.NewFlagSet("train", flag.ContinueOnError)
+	size := fs.Int("m", 3, "")
+	iterations := fs.Int("rounds", 0, "")
+
+	config := `{"m": 4, "rounds": 1, "p1": "random", "train": {"rounds": 100}, "play": {"m": 5}}`
+	if err := applyConfig(fs, strings.NewReader(config)); err != nil {
+		t.Fatalf("applyConfig(): Unexpected error %v", err)
+	}
+	if *size != 4 || *iterations != 100 {
+		t.Errorf("applyConfig(): Expected m=4 and rounds=100, actual %d and %d", *size, *iterations)
+	}
+
+	// Settings of the command itself must be its flags
+	fs = flag.NewFlagSet("train", flag.ContinueOnError)
+	if err := applyConfig(fs, strings.NewReader(`{"train": {"p1": "random"}}`)); err == nil {
+		t.Error("applyConfig(): Expected an error for a setting of another command")
+	}
+}
@@ -0,0 +1,687 @@
+// The implementation of an m,n,k-game with swappable Agents
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"mnkagent/agent"
+	"mnkagent/game"
+	"mnkagent/mnk"
+	"mnkagent/rl"
+	"mnkagent/train"
+)
+
+// Flags, shared by the subcommands that use them; the values here are their
+// defaults
+var (
+	// Game flags
+	m         = 3
+	n         = 3
+	k         = 3
+	noDisplay bool
+	gomoku    bool
+	seed      int64
+	rounds    int
+
+	// Player flags
+	p1Spec     = "human"
+	p2Spec     string
+	recordFile string
+
+	// Config flags
+	configFile string
+	configDump string
+
+	// RL flags
+	rlModelFile         = "rl.kw"
+	rlNoLearn           bool
+	rlAlgo              string
+	rlAfterstates       bool
+	rlAlphaSchedule     string
+	rlEpsilonSchedule   string
+	rlAlphaCount        bool
+	rlExplore           string
+	rlTempSchedule      string
+	rlUCBFactor         float64
+	rlOptimisticValue   float64
+	rlStore             string
+	rlMemoryCap         int
+	rlLambda            float64
+	rlTraces            = "replacing"
+	rlNN                bool
+	rlNNHidden          = "64"
+	rlNNOptimizer       = "adam"
+	rlNNLearningRate    = 0.001
+	rlEvalEvery         uint
+	rlEvalGames         = 100
+	rlEvalOpponent      = "random"
+	rlEvalLog           = "eval.csv"
+	rlReplaySize        int
+	rlReplayBatch       = 32
+	rlReplayPrioritized bool
+	rlReplayCheckpoint  bool
+	rlShaping           string
+	rlLeague            bool
+	rlLeagueEvery       uint = 1000
+	rlLeagueSize             = 10
+	rlLeagueWeights          = "self=0.5,past=0.3,baseline=0.2"
+	rlLeagueBaselines        = "random,minimax:1"
+
+	// Metrics flags
+	metricsLog    string
+	metricsEvery  uint = 100
+	metricsFormat string
+	metricsAddr   string
+
+	// Server flags
+	serveAddr     = "localhost:8080"
+	serveMaxGames = 100
+
+	// Network flags
+	hostAddr string
+	joinAddr string
+	netAgent = "human"
+
+	// Tournament flags
+	tournamentFormat = "roundrobin"
+	tournamentGames  = 10
+	tournamentRounds int
+	ratingsFile      = "ratings.json"
+)
+
+// Signs
+const (
+	X = "\033[36;1mX\033[0m"
+	O = "\033[31;1mO\033[0m"
+)
+
+// signs of the players by id
+var signs = [3]string{"", X, O}
+
+// trainLearners trains the learners of the models against each other, or a
+// league, for given rounds or until stop is closed
+func trainLearners(md *models, tm *trainingMonitor, rounds uint, stop <-chan struct{}) (log []int) {
+	log = make([]int, 3)
+
+	fmt.Printf("Commencing training (seed %d)...\n", seed)
+
+	if err := fileAccessible(rlModelFile); err != nil {
+		fmt.Println("Model file not accessible")
+		fmt.Println(err)
+		return
+	}
+
+	board, err := mnk.NewMNKBoard(m, n, k)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// The TD errors of both learners go to the metrics log
+	var errs = new(rl.ErrorStats)
+	var learners [3]mnk.Agent
+	for id := 1; id <= 2; id++ {
+		switch agent := md.newLearner(id, signs[id], true).(type) {
+		case *rl.RLAgent:
+			agent.Replay, agent.ReplayBatch, agent.Errors = md.replay, rlReplayBatch, errs
+			learners[id] = agent
+		case *rl.NNAgent:
+			agent.Replay, agent.ReplayBatch, agent.Errors = md.replay, rlReplayBatch, errs
+			learners[id] = agent
+		}
+	}
+
+	var view = &roundView{visual: !noDisplay}
+	var s = &train.Session{Env: board, Learners: learners, Observer: view, Stop: stop}
+	if rlLeague {
+		if s.League, err = newLeague(md, learners); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// Metrics on stdout replace the progress bar and boards
+	var ml *metricsLogger
+	var progressBar = metricsLog != "-"
+	if metricsLog != "" {
+		if ml, err = newMetricsLogger(metricsLog, metricsFormat, metricsEvery, md, errs); err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer ml.close()
+		noDisplay = noDisplay || !progressBar
+		view.visual = !noDisplay
+	}
+
+	var (
+		// For the progress bar
+		pTick         bool
+		termW         int
+		cleanupLine   string
+		displayH      int = n*2 + 3
+		displayBottom string
+		displayTop    string
+		progress      int
+		progressbar   string
+		color         string = "\033[41;3m"
+		colorDone     string = "\033[46;3m"
+
+		// Evaluations stop once one failed
+		evalEvery = rlEvalEvery
+	)
+
+	for i := 0; i <= displayH; i++ {
+		displayBottom += "\n"
+		displayTop += "\033[F"
+	}
+
+	s.Before = func(c uint, agents [3]mnk.Agent) {
+		view.agents = agents
+
+		pTick = c*100%rounds == 0
+		if pTick || c == 1 {
+			// Get terminal width
+			termW, _ = getTermSize()
+
+			// Generate cleanup line for terminal width
+			cleanupLine = ""
+			for i := 0; i < termW; i++ {
+				cleanupLine += " "
+			}
+			cleanupLine += "\r"
+
+			// Track progress
+			progress = int(c * 100 / rounds)
+			progressbar = generateProgressBar(progress, termW, color, "Training...")
+		}
+
+		if (pTick && progressBar) || !noDisplay {
+			// Clear the progress bar
+			fmt.Print(cleanupLine)
+		}
+	}
+
+	s.After = func(c uint, winner int, agents [3]mnk.Agent) error {
+		tm.game(winner, agents)
+		if ml != nil {
+			ml.game(winner, agents)
+		}
+
+		if !noDisplay {
+			// Print separator and cleanup progress bar
+			fmt.Printf("___________________________________\n%s\n", cleanupLine)
+		}
+
+		if evalEvery > 0 && c%evalEvery == 0 {
+			// Pause learning and measure the greedy policy
+			result, err := train.Evaluate(board, func(id int) (mnk.Agent, error) {
+				agent := md.newLearner(id, signs[id], false)
+				agent.Freeze()
+				return agent, nil
+			}, md.factory(rlEvalOpponent), rlEvalGames)
+			if err == nil {
+				err = train.LogEvaluation(rlEvalLog, c, md.iterations(), result)
+			}
+			if err != nil {
+				fmt.Print("\n[error] Evaluation failed: ", err, "\n")
+				evalEvery = 0
+			} else if noDisplay && progressBar {
+				fmt.Printf("%sEvaluation at %d: %d/%d/%d (win rate %.2f)\n",
+					cleanupLine, c, result.Wins, result.Losses, result.Draws,
+					result.WinRate())
+			}
+		}
+
+		if ml != nil {
+			if err := ml.log(c); err != nil {
+				fmt.Print("\n[error] Could not log metrics: ", err, "\n")
+				ml = nil
+			}
+		}
+
+		if (pTick && progressBar) || !noDisplay {
+			if !noDisplay && c != rounds {
+				// If not 100%, leave room for next board display
+				fmt.Print(displayBottom)
+			}
+
+			// Print progress bar
+			fmt.Print(progressbar)
+
+			if !noDisplay && c != rounds {
+				// If not 100%, go to display 0x0
+				fmt.Print(displayTop)
+			}
+		}
+
+		if !rlNoLearn && pTick {
+			// Store knowledge every 1/100 of rounds
+			tm.saved(md.save())
+		}
+		return nil
+	}
+
+	log, played, err := s.Run(rounds)
+	if err != nil {
+		fmt.Print("\n[error] ", err, "\n")
+	}
+
+	if err != nil || played < rounds {
+		if progressBar {
+			fmt.Print("\r", generateProgressBar(progress, termW, color, "Terminated."), "\n")
+		}
+		if ml != nil {
+			ml.flush(played)
+		}
+		if !rlNoLearn {
+			tm.saved(md.save())
+		}
+	} else {
+		if ml != nil {
+			ml.flush(rounds)
+		}
+
+		// Progress bar final touch
+		if progressBar {
+			fmt.Print(generateProgressBar(100, termW, colorDone, "Training completed"), "\n")
+		}
+	}
+
+	if s.League != nil {
+		s.League.PrintStandings(os.Stdout)
+	}
+	return
+}
+
+// newLeague creates the training league of the learners from the league
+// flags
+func newLeague(md *models, learners [3]mnk.Agent) (*train.League, error) {
+	weights, err := train.ParseWeights(rlLeagueWeights)
+	if err != nil {
+		return nil, err
+	}
+
+	var baselines []string
+	for _, spec := range strings.Split(rlLeagueBaselines, ",") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		if spec == "human" {
+			return nil, fmt.Errorf("league: baselines must be agents")
+		}
+		if _, err = md.newAgent(spec, 2, O, m, n, k); err != nil {
+			return nil, err
+		}
+		baselines = append(baselines, spec)
+	}
+
+	lg, err := train.NewLeague(learners, md.snapshot, rlLeagueSize, rlLeagueEvery, weights)
+	if err != nil {
+		return nil, err
+	}
+	for _, spec := range baselines {
+		lg.AddBaseline(spec, md.factory(spec))
+	}
+	return lg, nil
+}
+
+// play initiates game between the given agents on env for given rounds,
+// recording them to recorder
+func play(md *models, tm *trainingMonitor, recorder *game.Recorder, env *mnk.MNKBoard,
+	rounds int, p1, p2 mnk.Agent) (log []int) {
+	log = make([]int, 3)
+
+	if err := fileAccessible(rlModelFile); err != nil {
+		fmt.Println("Model file not accessible")
+		fmt.Println(err)
+	}
+
+	agents := [3]mnk.Agent{nil, p1, p2}
+	obs := game.Observers(&roundView{visual: true, agents: agents}, recorder)
+
+	for c, turn := 1, 1; c <= rounds; c++ {
+		// Start a new round and get the winner's id
+		pTurn := turn
+		var err error
+		turn, err = game.Round(env, agents, turn, obs) // Previous round's winner starts the game
+		if err != nil {
+			fmt.Print("\n[error] ", err, "\n")
+			return
+		}
+		if err = recorder.Err(); err != nil {
+			fmt.Print("\n[error] ", err, "\n")
+		}
+		log[turn]++ // Keep scores
+		tm.game(turn, agents)
+		if turn == 0 { // If it was a draw, next player starts the game
+			turn = mnk.Opponent(pTurn)
+		}
+
+		fmt.Print("___________________________________\n\n")
+
+		if !rlNoLearn {
+			tm.saved(md.save())
+		}
+	}
+	return
+}
+
+// host waits for a peer and plays given rounds as X against it
+func host(md *models, tm *trainingMonitor, recorder *game.Recorder, addr string,
+	rounds int) (log []int, err error) {
+	board, err := mnk.NewMNKBoard(m, n, k)
+	if err != nil {
+		return
+	}
+	conn, err := agent.HostRemote(addr, m, n, k, rounds)
+	if err != nil {
+		return
+	}
+
+	local, err := md.newAgent(netAgent, 1, X, m, n, k)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	remote := agent.NewRemoteAgent(2, O, m, n, k, conn)
+	defer remote.Close()
+
+	fmt.Println("Peer connected. Have fun.")
+	return play(md, tm, recorder, board, rounds, local, remote), nil
+}
+
+// join connects to a host and plays its game as O
+func join(md *models, tm *trainingMonitor, recorder *game.Recorder, addr string) (log []int, err error) {
+	conn, hm, hn, hk, rounds, err := agent.JoinRemote(addr)
+	if err != nil {
+		return
+	}
+
+	// Adopt the host's game definition once it proved valid
+	board, err := mnk.NewMNKBoard(hm, hn, hk)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	m, n, k = hm, hn, hk
+
+	local, err := md.newAgent(netAgent, 2, O, m, n, k)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	remote := agent.NewRemoteAgent(1, X, m, n, k, conn)
+	defer remote.Close()
+
+	fmt.Printf("Joined a %d,%d,%d game of %d rounds. Have fun.\n", m, n, k, rounds)
+	return play(md, tm, recorder, board, rounds, remote, local), nil
+}
+
+// roundView draws the rounds of its agents on the terminal
+type roundView struct {
+	visual bool
+	agents [3]mnk.Agent
+	screen screen
+}
+
+func (v *roundView) Started(env mnk.Environment) {
+	v.screen.reset()
+
+	if v.visual {
+		// Draw a new board
+		v.screen.display(env.GetState())
+	}
+}
+
+func (v *roundView) Moved(env mnk.Environment, player int, action mnk.Action) {
+	if v.visual {
+		// Clear previous messages
+		fmt.Printf("\033[2K\rAgent %s: %s / Agent %s: %s",
+			signs[1], v.agents[1].FetchMessage(),
+			signs[2], v.agents[2].FetchMessage())
+
+		v.screen.display(env.GetState())
+	}
+}
+
+func (v *roundView) Rejected(player int, err error) {
+	// Clear prompt
+	fmt.Print("\033[2K\r", err)
+}
+
+func (v *roundView) Ended(winner int, resigned bool) {
+	if !v.visual {
+		return
+	}
+
+	if resigned {
+		fmt.Printf("\033[2K\n\033[2K\r%s resigned. Congratulations %s\n",
+			signs[mnk.Opponent(winner)], signs[winner])
+		return
+	}
+
+	// Clear prompt
+	fmt.Print("\033[2K\n\033[2K\r")
+	if winner == 0 {
+		fmt.Println("It's a DRAW!")
+	} else {
+		fmt.Printf("We have a WINNER! Congratulations %s\n", signs[winner])
+	}
+}
+
+// screen draws boards on the terminal, each in place of the previous one
+type screen struct {
+	drawn bool // Whether there is a board to draw over
+}
+
+// reset starts drawing below the current board
+func (s *screen) reset() {
+	s.drawn = false
+}
+
+// display draws the board on the terminal
+func (s *screen) display(board mnk.State) {
+	var b mnk.MNKState = board.(mnk.MNKState)
+	var m, n = len(b[0]), len(b)
+	var mark string
+
+	if s.drawn {
+		// Reset to app's 0x0 position
+		reset := "\r"
+		for i := 0; i < n*2+1; i++ {
+			reset += "\033[F"
+		}
+		fmt.Print(reset)
+	}
+	s.drawn = true
+
+	for i := 0; i < n; i++ {
+		line := ""
+		if i == 0 {
+			// Top
+			line = "\u2554"
+			for j := 0; j < m; j++ {
+				line += "\u2550\u2550\u2550\u2550\u2550"
+				if j < m-1 {
+					line += "\u2564"
+				} else {
+					line += "\u2557"
+				}
+			}
+		} else {
+			// Middle
+			line = "\u2551"
+			for j := 0; j < m; j++ {
+				line += "\u2500\u2500\u2500\u2500\u2500"
+				if j < m-1 {
+					line += "\u253c"
+				} else {
+					line += "\u2551"
+				}
+			}
+		}
+		fmt.Println(line)
+
+		line = "\u2551"
+		for j := 0; j < m; j++ {
+			if j != 0 {
+				line += "\u2502"
+			}
+
+			index := i*m + j + 1
+			padding := [2]string{"", ""}
+
+			if b[i][j] == 0 {
+				mark = fmt.Sprintf("\033[37m%d\033[0m", index)
+
+				if index < 10 {
+					padding = [2]string{"  ", "  "}
+				} else if index < 100 {
+					padding = [2]string{" ", "  "}
+				} else if index < 1000 {
+					padding = [2]string{" ", " "}
+				}
+
+			} else {
+				mark = signs[b[i][j]]
+				padding = [2]string{"  ", "  "}
+			}
+
+			line += padding[0]
+			line += mark
+			line += padding[1]
+		}
+		line += "\u2551"
+		fmt.Println(line)
+
+		if i+1 == len(b) {
+			// Bottom
+			line = "\u255a"
+			for j := 0; j < m; j++ {
+				line += "\u2550\u2550\u2550\u2550\u2550"
+				if j < m-1 {
+					line += "\u2567"
+				} else {
+					line += "\u255d"
+				}
+			}
+			fmt.Println(line)
+		}
+	}
+}
+
+// printStats prints out statistics of given game log, and the random move
+// dispersion of the model if given
+func printStats(log []int, kw *rl.RLAgentKnowledge) {
+	var winnerSign string
+	winner := max(log)
+	if winner == 0 {
+		winnerSign = "DRAW"
+	} else {
+		winnerSign = signs[winner]
+	}
+	fmt.Printf("Stats: %s/%s/Draw = %d/%d/%d\nOverall winner: %s\n",
+		signs[1], signs[2], log[1], log[2], log[0],
+		winnerSign)
+
+	if kw != nil {
+		fmt.Println("Random move dispersion:")
+		for i, c := range kw.Dispersion() {
+			fmt.Printf("%d: %d\n", i+1, c)
+		}
+	}
+}
+
+// Get the key of the maximum array item
+func max(arr []int) (key int) {
+	var max int
+	for i := range arr {
+		if arr[i] > max {
+			max = arr[i]
+			key = i
+		}
+	}
+	return
+}
+
+// fileAccessible returns true if given path is writable
+func fileAccessible(path string) (err error) {
+	var f *os.File
+	f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	defer f.Close()
+	return
+}
+
+// Get terminal size
+func getTermSize() (w int, h int) {
+	// TODO: Use a terminal ui library like termbox-go
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	d, _ := cmd.Output()
+	fmt.Sscan(string(d), &h, &w)
+
+	// Not a terminal; fall back to the classic dimensions
+	if w <= 0 || h <= 0 {
+		w, h = 80, 24
+	}
+	return
+}
+
+// generateProgressBar constructs a progress bar with given information
+func generateProgressBar(progress, width int, color string, msg string) string {
+	var reset = []byte("\033[0m")
+	var ellipsis = []byte("...")
+	var fProgress = fmt.Sprintf("%d%%", progress)
+	var pb = make([]byte, width, 2*width+20)
+	var m = make([]byte, len(msg), len(msg)+len(ellipsis))
+	var p = make([]byte, len(fProgress))
+
+	// Generate progress indicator string
+	copy(p, fProgress)
+
+	// Truncate message if necessary
+	// TODO: Either escape special commands like color, or take care not to truncate them
+	copy(m, msg)
+	if len(m) > width-8 {
+		m = m[:width-5-len(ellipsis)]
+		copy(m[len(m)-len(ellipsis):], ellipsis)
+	}
+
+	// Fill buffer with empty space
+	for i := 0; i < width; i++ {
+		copy(pb[i:], " ")
+	}
+
+	// Progress indicator position
+	pIndex := width*progress/100 + len(color)
+	// Message position
+	mOffset := (width-8)/2 - len(m)/2
+	if mOffset < 1 {
+		mOffset = 1
+	}
+
+	// Buffer message
+	copy(pb[mOffset:], m)
+
+	// Buffer progress indicator
+	copy(pb[len(pb)-len(p)-1:], p)
+
+	// Extend buffer to support color, reset and BOL
+	pb = pb[0 : len(pb)+len(color)+len(reset)+len("\r")]
+
+	// Add progress color
+	copy(pb[len(color):], pb[:])
+	copy(pb[:len(color)], color)
+
+	// Reset progress color
+	copy(pb[pIndex+len(reset):], pb[pIndex:])
+	copy(pb[pIndex:pIndex+len(reset)], reset)
+
+	// Set cursor to BOL
+	copy(pb[len(pb)-len("\r"):], "\r")
+
+	return string(pb)
+}
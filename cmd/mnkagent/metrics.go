@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"mnkagent/mnk"
+	"mnkagent/rl"
+)
+
+// metricsRecord summarizes a window of training games
+type metricsRecord struct {
+	Iteration       uint    `json:"iteration"`
+	ModelIterations uint    `json:"model_iterations"`
+	Games           int     `json:"games"`
+	XWinRate        float64 `json:"x_win_rate"`
+	OWinRate        float64 `json:"o_win_rate"`
+	DrawRate        float64 `json:"draw_rate"`
+	NewStates       uint    `json:"new_states"`
+	MeanAbsTDError  float64 `json:"mean_abs_td_error"`
+	TDUpdates       int     `json:"td_updates"`
+	Exploration     float64 `json:"exploration"`
+	GamesPerSecond  float64 `json:"games_per_second"`
+	Elapsed         float64 `json:"elapsed"`
+}
+
+// metricsColumns are the CSV columns of a record
+var metricsColumns = []string{"iteration", "model_iterations", "games",
+	"x_win_rate", "o_win_rate", "draw_rate", "new_states", "mean_abs_td_error",
+	"td_updates", "exploration", "games_per_second", "elapsed"}
+
+func (r metricsRecord) row() []string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	return []string{
+		strconv.FormatUint(uint64(r.Iteration), 10),
+		strconv.FormatUint(uint64(r.ModelIterations), 10),
+		strconv.Itoa(r.Games),
+		f(r.XWinRate), f(r.OWinRate), f(r.DrawRate),
+		strconv.FormatUint(uint64(r.NewStates), 10),
+		strconv.FormatFloat(r.MeanAbsTDError, 'g', 6, 64),
+		strconv.Itoa(r.TDUpdates),
+		f(r.Exploration), f(r.GamesPerSecond), f(r.Elapsed),
+	}
+}
+
+// metricsLogger writes a record of training every few games, as JSON lines
+// or CSV
+type metricsLogger struct {
+	w      io.Writer
+	file   *os.File
+	csv    *csv.Writer
+	every  uint
+	start  time.Time
+	window time.Time
+
+	// The models trained and the TD errors of their learners
+	models *models
+	errors *rl.ErrorStats
+
+	// Current window
+	results     [3]int
+	states      uint
+	exploration float64 // Of the learner in the latest game
+}
+
+// newMetricsLogger logs the training of the models, whose learners report
+// their TD errors to errs, to path, or to stdout for "-", in the given format
+// or the one implied by the extension of path
+func newMetricsLogger(path, format string, every uint, md *models, errs *rl.ErrorStats) (*metricsLogger, error) {
+	if format == "" {
+		format = "json"
+		if filepath.Ext(path) == ".csv" {
+			format = "csv"
+		}
+	}
+	if format != "json" && format != "csv" {
+		return nil, fmt.Errorf("metrics: unknown format %q (json|csv)", format)
+	}
+	if every == 0 {
+		return nil, fmt.Errorf("metrics: invalid interval %d", every)
+	}
+
+	l := &metricsLogger{w: os.Stdout, every: every, models: md, errors: errs}
+	if path != "-" {
+		_, statErr := os.Stat(path)
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		l.w, l.file = file, file
+
+		// Appending to an existing log keeps its header
+		if format == "csv" && !os.IsNotExist(statErr) {
+			l.csv = csv.NewWriter(file)
+		}
+	}
+	if format == "csv" && l.csv == nil {
+		l.csv = csv.NewWriter(l.w)
+		l.csv.Write(metricsColumns)
+		l.csv.Flush()
+	}
+
+	l.start = time.Now()
+	l.window = l.start
+	l.states = md.learned()
+	errs.Take()
+	return l, nil
+}
+
+// game records the winner of a training game, zero for a draw, and the
+// exploration rate of the learner among the agents that played it
+func (l *metricsLogger) game(winner int, agents [3]mnk.Agent) {
+	l.results[winner]++
+
+	for _, p := range agents[1:] {
+		if e, ok := p.(interface{ CurrentExplorationFactor() float64 }); ok {
+			l.exploration = e.CurrentExplorationFactor()
+			break
+		}
+	}
+}
+
+// log writes the record of the window ending at iteration c when due
+func (l *metricsLogger) log(c uint) error {
+	if c%l.every != 0 {
+		return nil
+	}
+	return l.flush(c)
+}
+
+// flush writes the record of the current window, if it has any games
+func (l *metricsLogger) flush(c uint) error {
+	games := l.results[0] + l.results[1] + l.results[2]
+	if games == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	states := l.models.learned()
+	r := metricsRecord{
+		Iteration:       c,
+		ModelIterations: l.models.iterations(),
+		Games:           games,
+		XWinRate:        float64(l.results[1]) / float64(games),
+		OWinRate:        float64(l.results[2]) / float64(games),
+		DrawRate:        float64(l.results[0]) / float64(games),
+		NewStates:       states - l.states,
+		Exploration:     l.exploration,
+		Elapsed:         now.Sub(l.start).Seconds(),
+	}
+	r.MeanAbsTDError, r.TDUpdates = l.errors.Take()
+	if d := now.Sub(l.window).Seconds(); d > 0 {
+		r.GamesPerSecond = float64(games) / d
+	}
+
+	l.results = [3]int{}
+	l.states = states
+	l.window = now
+
+	if l.csv != nil {
+		l.csv.Write(r.row())
+		l.csv.Flush()
+		return l.csv.Error()
+	}
+	return json.NewEncoder(l.w).Encode(r)
+}
+
+// close releases the log file
+func (l *metricsLogger) close() error {
+	if l.file != nil {
+		return l.file.Close()
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mnkagent/mnk"
+	"mnkagent/rl"
+)
+
+func TestMetricsLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.jsonl")
+	var errs rl.ErrorStats
+	l, err := newMetricsLogger(path, "", 2, &models{kw: new(rl.RLAgentKnowledge)}, &errs)
+	if err != nil {
+		t.Fatalf("newMetricsLogger(): Unexpected error %v", err)
+	}
+
+	learner := rl.NewRLAgent(1, X, 3, 3, 3, new(rl.RLAgentKnowledge), true)
+	learner.ExplorationFactor = 0.1
+	errs.Add(-0.5)
+	errs.Add(0.25)
+	for c, winner := range []int{1, 0, 2} {
+		l.game(winner, [3]mnk.Agent{nil, learner, nil})
+		if err = l.log(uint(c + 1)); err != nil {
+			t.Fatalf("log(): Unexpected error %v", err)
+		}
+	}
+	l.flush(3)
+	l.close()
+
+	file, _ := os.Open(path)
+	defer file.Close()
+	var records []metricsRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r metricsRecord
+		if err = json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("log(): Expected JSON lines, actual %q", scanner.Text())
+		}
+		records = append(records, r)
+	}
+
+	if len(records) != 2 || records[0].Games != 2 || records[1].Iteration != 3 {
+		t.Fatalf("log(): Expected records of 2 and 1 games, actual %+v", records)
+	}
+	if r := records[0]; r.XWinRate != 0.5 || r.DrawRate != 0.5 || r.TDUpdates != 2 || r.MeanAbsTDError != 0.375 ||
+		r.Exploration != 0.1 {
+		t.Errorf("log(): Expected rates of 0.5, a mean TD error of 0.375 and exploration 0.1, actual %+v", r)
+	}
+	if records[1].OWinRate != 1 || records[1].TDUpdates != 0 {
+		t.Errorf("log(): Expected a window of its own, actual %+v", records[1])
+	}
+}
+
+func TestMetricsLoggerCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.csv")
+	for i := 0; i < 2; i++ {
+		l, err := newMetricsLogger(path, "", 1, &models{kw: new(rl.RLAgentKnowledge)}, nil)
+		if err != nil {
+			t.Fatalf("newMetricsLogger(): Unexpected error %v", err)
+		}
+		l.game(1, [3]mnk.Agent{})
+		l.log(1)
+		l.close()
+	}
+
+	file, _ := os.Open(path)
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil || len(rows) != 3 || rows[0][0] != "iteration" || rows[2][3] != "1.0000" {
+		t.Errorf("log(): Expected a header and 2 records, actual %v (%v)", rows, err)
+	}
+}
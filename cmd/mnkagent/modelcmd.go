@@ -0,0 +1,596 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"mnkagent/mnk"
+	"mnkagent/rl"
+)
+
+// Boards up to this many cells have their reachable positions enumerated
+// rather than estimated
+const modelExactCoverageCells = 12
+
+// modelCommand runs a model inspection command on the loaded models
+func modelCommand(md *models, args []string) error {
+	if len(args) == 0 {
+		return errors.New("model: missing command " +
+			"(status|histogram|top|lookup|depth|coverage|export|import|merge|prune|diff)")
+	}
+
+	fs := flag.NewFlagSet("model "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "status":
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		modelStatus(md)
+
+	case "histogram":
+		bins := fs.Int("bins", 20, "Number of histogram bins")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *bins < 1 {
+			return fmt.Errorf("model: invalid number of bins %d", *bins)
+		}
+		modelHistogram(md.kw, *bins)
+
+	case "top":
+		count := fs.Int("n", 10, "Number of states to show")
+		bottom := fs.Bool("bottom", false, "Show the lowest valued states instead")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		modelTop(md.kw, *count, *bottom)
+
+	case "lookup":
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return errors.New("model: lookup takes a position, e.g. xo..x.o.. " +
+				"with x for the agent's marks, o for the opponent's and . for empty cells")
+		}
+		return modelLookup(md.kw, fs.Arg(0))
+
+	case "depth":
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		modelDepth(md.kw)
+
+	case "coverage":
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		modelCoverage(md.kw)
+
+	case "export":
+		format := fs.String("format", "", "Output format (json|csv) "+
+			"(default the extension of -o, or json)")
+		output := fs.String("o", "", "Output file (default standard output)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return modelExportCommand(md.kw, *format, *output)
+
+	case "import":
+		format := fs.String("format", "", "Input format (json|csv) "+
+			"(default the extension of the file)")
+		merge := fs.Bool("merge", false, "Keep the model's values that are not "+
+			"in the file")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return errors.New("model: import takes a file")
+		}
+		return modelImportCommand(md.kw, *format, fs.Arg(0), *merge)
+
+	case "merge":
+		output := fs.String("o", "", "Output model file")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *output == "" || fs.NArg() < 2 {
+			return errors.New("model: merge takes an output file (-o) and two or more models")
+		}
+		return modelMergeCommand(fs.Args(), *output)
+
+	case "prune":
+		minVisits := fs.Uint("min-visits", 1, "Drop states visited fewer times")
+		epsilon := fs.Float64("epsilon", 0, "Drop states valued within epsilon of zero (default none)")
+		output := fs.String("o", "", "Output model file (default the model itself)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *output == "" {
+			*output = rlModelFile
+		}
+		return modelPruneCommand(md.kw, *minVisits, *epsilon, *output)
+
+	case "diff":
+		count := fs.Int("n", 20, "Number of changes to show")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 2 {
+			return errors.New("model: diff takes two models")
+		}
+		return modelDiffCommand(fs.Arg(0), fs.Arg(1), *count)
+
+	default:
+		return fmt.Errorf("model: unknown command %q", args[0])
+	}
+
+	return nil
+}
+
+// modelExportCommand writes the RL model in a portable format
+func modelExportCommand(kw *rl.RLAgentKnowledge, format, output string) error {
+	if format == "" && output == "" {
+		format = "json"
+	}
+	format, err := rl.ModelFormat(format, output)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return rl.NewModelExport(kw, m, n, k).Write(w, format)
+}
+
+// modelImportCommand loads values from a portable file into the RL model and
+// stores it
+func modelImportCommand(kw *rl.RLAgentKnowledge, format, input string, merge bool) error {
+	format, err := rl.ModelFormat(format, input)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	e, err := rl.ReadModelExport(file, format)
+	if err != nil {
+		return err
+	}
+	if err = e.Apply(kw, m, n, merge); err != nil {
+		return err
+	}
+
+	if !kw.SaveToFile(rlModelFile) {
+		return fmt.Errorf("model: could not store %s", rlModelFile)
+	}
+	fmt.Printf("Imported %d entries into %s\n", len(e.Entries), rlModelFile)
+	return nil
+}
+
+// modelMergeCommand combines the given models into output
+func modelMergeCommand(paths []string, output string) error {
+	var models []*rl.RLAgentKnowledge
+	for _, path := range paths {
+		kw, err := rl.ReadModel(path)
+		if err != nil {
+			return err
+		}
+		models = append(models, kw)
+	}
+
+	merged, err := rl.MergeModels(models)
+	if err != nil {
+		return err
+	}
+	if !merged.SaveToFile(output) {
+		return fmt.Errorf("model: could not store %s", output)
+	}
+	fmt.Printf("Merged %d models into %s: %d states, %d iterations\n", len(models),
+		output, len(merged.Values), merged.Iterations)
+	return nil
+}
+
+// modelPruneCommand drops rarely visited or near-zero states of the model and
+// stores the result in output
+func modelPruneCommand(kw *rl.RLAgentKnowledge, minVisits uint, epsilon float64, output string) error {
+	if minVisits > 0 && len(kw.Stats) == 0 {
+		return errors.New("model: the model has no visit counts, prune with -min-visits 0")
+	}
+
+	before := len(kw.Values)
+	pruned := rl.PruneModel(kw, minVisits, epsilon)
+	if !kw.SaveToFile(output) {
+		return fmt.Errorf("model: could not store %s", output)
+	}
+	fmt.Printf("Pruned %d of %d states into %s\n", pruned, before, output)
+	return nil
+}
+
+// modelDiffCommand prints the states that changed most between two models
+func modelDiffCommand(beforePath, afterPath string, count int) error {
+	before, err := rl.ReadModel(beforePath)
+	if err != nil {
+		return err
+	}
+	after, err := rl.ReadModel(afterPath)
+	if err != nil {
+		return err
+	}
+
+	changes := rl.DiffModels(before, after)
+
+	var added, removed int
+	var sum float64
+	for _, c := range changes {
+		if _, ok := before.Values[c.State]; !ok {
+			added++
+		} else if _, ok := after.Values[c.State]; !ok {
+			removed++
+		}
+		sum += math.Abs(c.Delta())
+	}
+
+	fmt.Printf("Iterations: %d -> %d\n", before.Iterations, after.Iterations)
+	fmt.Printf("States: %d -> %d (%d added, %d removed, %d changed)\n",
+		len(before.Values), len(after.Values), added, removed, len(changes)-added-removed)
+	if len(changes) > 0 {
+		fmt.Printf("Mean absolute change: %f\n", sum/float64(len(changes)))
+	}
+
+	if count < len(changes) {
+		changes = changes[:count]
+	}
+	for _, c := range changes {
+		var from, to = fmt.Sprintf("%9f", c.Before), fmt.Sprintf("%9f", c.After)
+		if _, ok := before.Values[c.State]; !ok {
+			from = fmt.Sprintf("%9s", "-")
+		}
+		if _, ok := after.Values[c.State]; !ok {
+			to = fmt.Sprintf("%9s", "-")
+		}
+		fmt.Printf("%s  %s -> %s  (%+f)\n", c.State, from, to, c.Delta())
+	}
+	return nil
+}
+
+// modelStatus prints a summary of the RL model
+func modelStatus(md *models) {
+	fmt.Println("Reinforcement learning model report")
+	fmt.Printf("Iterations: %d\n", md.kw.Iterations)
+	fmt.Printf("Algorithm: %s\n", md.kw.AlgorithmName())
+	fmt.Printf("Afterstates: %t\n", md.kw.Afterstates)
+	fmt.Printf("Exploration: %s\n", md.kw.ExplorationName())
+	fmt.Printf("Learned states: %d\n", len(md.kw.Values))
+	fmt.Printf("Count-based learning rate: %t\n", md.kw.CountBasedAlpha)
+
+	var first = true
+	var max, min float64
+	for _, v := range md.kw.Values {
+		if v > max || first {
+			max = v
+		}
+		if v < min || first {
+			min = v
+		}
+		first = false
+	}
+	fmt.Printf("Maximum value: %f\n", max)
+	fmt.Printf("Minimum value: %f\n", min)
+
+	// States only initialized by lookups have never been updated
+	var confidence [5]int
+	var visited int
+	for key := range md.kw.Values {
+		stats := md.kw.Stats[key]
+		if stats.Visits > 0 {
+			visited++
+		}
+		switch u := stats.Updates; {
+		case u == 0:
+			confidence[0]++
+		case u == 1:
+			confidence[1]++
+		case u < 10:
+			confidence[2]++
+		case u < 100:
+			confidence[3]++
+		default:
+			confidence[4]++
+		}
+	}
+	fmt.Printf("Visited states: %d\n", visited)
+	fmt.Printf("States by updates: never %d, once %d, 2-9 %d, 10-99 %d, 100+ %d\n",
+		confidence[0], confidence[1], confidence[2], confidence[3], confidence[4])
+
+	if md.nn != nil {
+		fmt.Printf("Network: %v units, %s optimizer, %d iterations\n",
+			md.nn.Net.Sizes, md.nn.Net.Optimizer, md.nn.Iterations)
+	}
+	if s := md.kw.AlphaSchedule; s != nil {
+		fmt.Printf("Learning rate: %f (%s)\n", s.Value(md.kw.Iterations), s)
+	}
+	if s := md.kw.EpsilonSchedule; s != nil {
+		fmt.Printf("Exploration factor: %f (%s)\n", s.Value(md.kw.Iterations), s)
+	}
+	if s := md.kw.TemperatureSchedule; s != nil {
+		fmt.Printf("Temperature: %f (%s)\n", s.Value(md.kw.Iterations), s)
+	}
+}
+
+// modelHistogram prints the distribution of the learned values
+func modelHistogram(kw *rl.RLAgentKnowledge, bins int) {
+	if len(kw.Values) == 0 {
+		fmt.Println("No learned states")
+		return
+	}
+
+	var min, max = math.Inf(1), math.Inf(-1)
+	for _, v := range kw.Values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+
+	var width = (max - min) / float64(bins)
+	var counts = make([]int, bins)
+	for _, v := range kw.Values {
+		b := bins - 1
+		if width > 0 {
+			b = int((v - min) / width)
+		}
+		if b >= bins {
+			b = bins - 1
+		}
+		counts[b]++
+	}
+
+	var most = counts[0]
+	for _, c := range counts {
+		if c > most {
+			most = c
+		}
+	}
+
+	for b, c := range counts {
+		bar := strings.Repeat("█", c*50/most)
+		fmt.Printf("[%7.3f, %7.3f) %8d %s\n", min+float64(b)*width,
+			min+float64(b+1)*width, c, bar)
+	}
+}
+
+// modelTop prints the highest, or lowest, valued states as boards
+func modelTop(kw *rl.RLAgentKnowledge, count int, bottom bool) {
+	var keys []string
+	for key := range kw.Values {
+		if len(key) == m*n {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		vi, vj := kw.Values[keys[i]], kw.Values[keys[j]]
+		if vi == vj {
+			return keys[i] < keys[j]
+		}
+		return vi > vj != bottom
+	})
+
+	if count < len(keys) {
+		keys = keys[:count]
+	}
+
+	fmt.Printf("X is the agent, O the opponent\n\n")
+	for i, key := range keys {
+		s, _ := unmarshallKey(key, m, n)
+		fmt.Printf("#%d: %s = %f\n", i+1, key, kw.Values[key])
+		new(screen).display(s)
+		fmt.Println()
+	}
+}
+
+// modelLookup prints the value of the given position, and the values of the
+// agent's moves from it
+func modelLookup(kw *rl.RLAgentKnowledge, position string) error {
+	s, err := unmarshallKey(position, m, n)
+	if err != nil {
+		return err
+	}
+
+	agent := rl.NewRLAgent(1, X, m, n, k, kw, false)
+	agent.Freeze()
+
+	new(screen).display(s)
+
+	key := agent.Key(s, rl.Terminal)
+	if v, ok := kw.Values[key]; ok {
+		stats := kw.Stats[key]
+		fmt.Printf("%s = %f (%d visits, %d updates, last at %d)\n", key, v,
+			stats.Visits, stats.Updates, stats.LastUpdated)
+	} else {
+		fmt.Printf("%s has not been learned\n", key)
+	}
+
+	fmt.Println("Moves:")
+	for _, a := range s.EmptyCells() {
+		key := agent.Key(s, a)
+		v, ok := kw.Values[key]
+		if !ok {
+			fmt.Printf("  %d: not learned\n", a.Y*m+a.X+1)
+			continue
+		}
+		stats := kw.Stats[key]
+		fmt.Printf("  %d: %f (%d visits, %d updates, last at %d)\n", a.Y*m+a.X+1, v,
+			stats.Visits, stats.Updates, stats.LastUpdated)
+	}
+	return nil
+}
+
+// modelDepth prints the number of learned states and their mean value by the
+// number of marks on the board
+func modelDepth(kw *rl.RLAgentKnowledge) {
+	var counts = make([]int, m*n+1)
+	var sums = make([]float64, m*n+1)
+	for key, v := range kw.Values {
+		s, err := unmarshallKey(key, m, n)
+		if err != nil {
+			continue
+		}
+		d := m*n - len(s.EmptyCells())
+		counts[d]++
+		sums[d] += v
+	}
+
+	fmt.Println("Marks   States   Mean value")
+	for d, c := range counts {
+		if c > 0 {
+			fmt.Printf("%5d %8d %12f\n", d, c, sums[d]/float64(c))
+		}
+	}
+}
+
+// modelCoverage prints the share of reachable positions the model has learned
+func modelCoverage(kw *rl.RLAgentKnowledge) {
+	// Keys are in the agent's perspective; the side with more marks moved
+	// first, and the agent has just moved
+	var learned = make(map[string]bool)
+	for key := range kw.Values {
+		s, err := unmarshallKey(key, m, n)
+		if err != nil {
+			continue
+		}
+		var own, opponent int
+		for i := range s {
+			for j := range s[i] {
+				switch s[i][j] {
+				case 1:
+					own++
+				case 2:
+					opponent++
+				}
+			}
+		}
+		if own < opponent {
+			continue
+		}
+		if own == opponent {
+			s = s.Swapped()
+		}
+		learned[rl.MarshallState(1, s, rl.Terminal)] = true
+	}
+
+	if m*n <= modelExactCoverageCells {
+		reachable := reachablePositions(m, n, k)
+		var covered int
+		for key := range learned {
+			if reachable[key] {
+				covered++
+			}
+		}
+		fmt.Printf("Learned positions: %d\n", covered)
+		fmt.Printf("Reachable positions: %d\n", len(reachable))
+		fmt.Printf("Coverage: %.2f%%\n", 100*float64(covered)/float64(len(reachable)))
+		return
+	}
+
+	total := positionsUpperBound(m, n)
+	coverage, _ := new(big.Float).Quo(
+		new(big.Float).SetInt64(int64(len(learned))), new(big.Float).SetInt(total)).Float64()
+	fmt.Printf("Learned positions: %d\n", len(learned))
+	fmt.Printf("Reachable positions: at most %s\n", total)
+	fmt.Printf("Coverage: at least %.2g%%\n", 100*coverage)
+}
+
+// reachablePositions enumerates the positions of legal games on an m by n
+// board, keyed by rl.MarshallState of the first player
+func reachablePositions(m, n, k int) map[string]bool {
+	env, _ := mnk.NewMNKBoard(m, n, k) // Validated by the model command
+	seen := make(map[string]bool)
+
+	var walk func(s mnk.MNKState, turn int)
+	walk = func(s mnk.MNKState, turn int) {
+		key := rl.MarshallState(1, s, rl.Terminal)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		for _, a := range s.EmptyCells() {
+			env.SetState(s)
+			over := env.EvaluateAction(turn, a) != 0
+
+			next := s.Clone()
+			next[a.Y][a.X] = turn
+			if over {
+				seen[rl.MarshallState(1, next, rl.Terminal)] = true
+				continue
+			}
+			walk(next, mnk.Opponent(turn))
+		}
+	}
+
+	var empty = make(mnk.MNKState, n)
+	for i := range empty {
+		empty[i] = make([]int, m)
+	}
+	walk(empty, 1)
+	return seen
+}
+
+// positionsUpperBound counts the boards with alternating numbers of marks,
+// ignoring that games end on a win
+func positionsUpperBound(m, n int) *big.Int {
+	var total = new(big.Int)
+	var cells = int64(m * n)
+	for d := int64(0); d <= cells; d++ {
+		b := new(big.Int).Binomial(cells, d)
+		b.Mul(b, new(big.Int).Binomial(d, d/2))
+		total.Add(total, b)
+	}
+	return total
+}
+
+// unmarshallKey decodes a table key, or a position in the same notation, into
+// a state with the agent as player 1. Both the afterstate (x, o, .) and the
+// state-action (X, O, -) notations are accepted.
+func unmarshallKey(key string, m, n int) (mnk.MNKState, error) {
+	if len(key) != m*n {
+		return nil, fmt.Errorf("model: position %q does not have %d cells", key, m*n)
+	}
+
+	var s = make(mnk.MNKState, n)
+	for i := range s {
+		s[i] = make([]int, m)
+		for j := range s[i] {
+			switch key[i*m+j] {
+			case 'x', 'X':
+				s[i][j] = 1
+			case 'o', 'O':
+				s[i][j] = 2
+			case '.', '-':
+			default:
+				return nil, fmt.Errorf("model: invalid cell %q in position %q",
+					key[i*m+j], key)
+			}
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"mnkagent/mnk"
+	"mnkagent/rl"
+)
+
+func TestReachablePositions(t *testing.T) {
+	// The well-known number of tic-tac-toe positions, the empty board included
+	if r := len(reachablePositions(3, 3, 3)); r != 5478 {
+		t.Errorf("reachablePositions(): Expected 5478 positions, actual %d", r)
+	}
+}
+
+func TestUnmarshallKey(t *testing.T) {
+	var s = mnk.MNKState{{1, 2, 0}, {0, 1, 0}, {2, 0, 0}}
+	var key = rl.MarshallAfterstate(1, s, mnk.MNKAction{X: 2, Y: 2})
+
+	u, err := unmarshallKey(key, 3, 3)
+	if err != nil {
+		t.Fatalf("unmarshallKey(): Unexpected error %v", err)
+	}
+	if u[2][2] != 1 || u[0][1] != 2 || u[1][0] != 0 {
+		t.Errorf("unmarshallKey(%q): Unexpected state %v", key, u)
+	}
+	if k := rl.MarshallState(1, u, rl.Terminal); k != "XO--X-O-X" {
+		t.Errorf("unmarshallKey(%q): Expected XO--X-O-X, actual %q", key, k)
+	}
+
+	for _, key := range []string{"xo..x.o.", "xo..x.o.z"} {
+		if _, err := unmarshallKey(key, 3, 3); err == nil {
+			t.Errorf("unmarshallKey(%q): Expected an error", key)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"mnkagent/mnk"
+	"mnkagent/rl"
+	"mnkagent/train"
+)
+
+// models are the models of the learners of a run
+type models struct {
+	kw      *rl.RLAgentKnowledge
+	nn      *rl.NNModel // The network, if one is in use
+	replay  *rl.ReplayBuffer
+	shaping *rl.RewardShaping
+}
+
+// loadModels loads the RL model, and the network and the replay buffer if
+// they are in use, and applies the model flags; it reports whether the model
+// could be read
+func loadModels() (md *models, loaded bool, err error) {
+	md = &models{kw: new(rl.RLAgentKnowledge)}
+	loaded = md.kw.LoadFromFile(rlModelFile)
+
+	if rlNN {
+		if md.nn, err = loadNNModel(rlModelFile + ".nn"); err != nil {
+			return
+		}
+	}
+
+	if err = md.applyFlags(); err != nil {
+		return
+	}
+
+	if rlReplaySize > 0 {
+		md.replay, err = loadReplayBuffer(rlModelFile + ".replay")
+	}
+	return
+}
+
+// loadNNModel reads the network at path, or creates a new one from the flags
+// if there is none yet
+func loadNNModel(path string) (*rl.NNModel, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		var hidden []int
+		for _, h := range strings.Split(rlNNHidden, ",") {
+			size, err := strconv.Atoi(strings.TrimSpace(h))
+			if err != nil {
+				return nil, fmt.Errorf("invalid hidden layer size %q", h)
+			}
+			hidden = append(hidden, size)
+		}
+		return rl.NewNNModel(m, n, hidden, rlNNOptimizer, rlNNLearningRate)
+	}
+
+	model := new(rl.NNModel)
+	if !model.LoadFromFile(path) {
+		return nil, fmt.Errorf("could not load network %s", path)
+	}
+	if model.M != m || model.N != n {
+		return nil, fmt.Errorf("network %s was trained on a %d,%d board", path,
+			model.M, model.N)
+	}
+	return model, nil
+}
+
+// loadReplayBuffer returns an empty replay buffer, or the checkpoint at path
+// if checkpointing is enabled and there is one
+func loadReplayBuffer(path string) (*rl.ReplayBuffer, error) {
+	buffer := rl.NewReplayBuffer(rlReplaySize, rlReplayPrioritized)
+	if _, err := os.Stat(path); !rlReplayCheckpoint || os.IsNotExist(err) {
+		return buffer, nil
+	}
+
+	if !buffer.LoadFromFile(path) {
+		return nil, fmt.Errorf("could not load replay buffer %s", path)
+	}
+	if buffer.Capacity != rlReplaySize {
+		return nil, fmt.Errorf("replay buffer %s holds %d transitions, not %d",
+			path, buffer.Capacity, rlReplaySize)
+	}
+	buffer.Prioritized = rlReplayPrioritized
+	return buffer, nil
+}
+
+// learner is an agent that learns from its games and can be frozen
+type learner interface {
+	mnk.Agent
+
+	// Freeze turns the agent into a greedy, non-learning player
+	Freeze()
+}
+
+// newLearner returns the learning agent of the models: the network if one is
+// in use, the RL table otherwise
+func (md *models) newLearner(id int, sign string, learn bool) learner {
+	if md.nn != nil {
+		return md.newNNAgent(id, sign, m, n, k, learn)
+	}
+	return md.newRLAgent(id, sign, m, n, k, learn)
+}
+
+// newRLAgent returns an agent of the RL table with the player's settings
+func (md *models) newRLAgent(id int, sign string, m, n, k int, learn bool) *rl.RLAgent {
+	agent := rl.NewRLAgent(id, sign, m, n, k, md.kw, learn)
+	playerParams[id].applyRL(agent)
+	agent.ReplacingTraces = rlTraces == "replacing"
+	agent.Shaping = md.shaping
+	return agent
+}
+
+// newNNAgent returns an agent of the network with the player's settings
+func (md *models) newNNAgent(id int, sign string, m, n, k int, learn bool) *rl.NNAgent {
+	agent := rl.NewNNAgent(id, sign, m, n, k, md.nn, learn)
+	playerParams[id].applyNN(agent)
+	return agent
+}
+
+// save stores the RL table, and the network and the replay buffer if they
+// are in use, and returns how long it took
+func (md *models) save() time.Duration {
+	start := time.Now()
+
+	md.kw.SaveToFile(rlModelFile)
+	if md.nn != nil {
+		md.nn.SaveToFile(rlModelFile + ".nn")
+	}
+	if md.replay != nil && rlReplayCheckpoint {
+		md.replay.SaveToFile(rlModelFile + ".replay")
+	}
+	return time.Since(start)
+}
+
+// snapshot returns a frozen copy of the model in use for a league
+func (md *models) snapshot() train.Snapshot {
+	if md.nn != nil {
+		model := md.nn.Snapshot()
+		return train.Snapshot{Iteration: model.Iterations, Opponent: func(id int) (mnk.Agent, error) {
+			agent := rl.NewNNAgent(id, signs[id], m, n, k, model, false)
+			agent.Freeze()
+			return agent, nil
+		}}
+	}
+
+	kw := md.kw.Snapshot()
+	return train.Snapshot{Iteration: kw.Iterations, Opponent: func(id int) (mnk.Agent, error) {
+		agent := rl.NewRLAgent(id, signs[id], m, n, k, kw, false)
+		agent.Freeze()
+		return agent, nil
+	}}
+}
+
+// iterations returns the training iterations of the model in use
+func (md *models) iterations() uint {
+	if md.nn != nil {
+		return md.nn.Iterations
+	}
+	return md.kw.Iterations
+}
+
+// learned returns the number of keys the RL table has learned
+func (md *models) learned() uint {
+	if md.nn != nil {
+		return 0
+	}
+	return md.kw.Learned()
+}
+
+// size returns the number of values in memory and the iterations of the
+// model in use
+func (md *models) size() (entries int, iterations uint) {
+	if md.nn != nil {
+		return 0, md.nn.Trained()
+	}
+	return md.kw.Size(), md.kw.Trained()
+}
+
+// applyFlags validates the RL flags and stores the algorithm and decay
+// schedules given on the command line in the model; otherwise the model's own
+// settings stay in effect
+func (md *models) applyFlags() error {
+	if rlAlgo != "" {
+		if _, ok := rl.Algorithms[rlAlgo]; !ok {
+			return fmt.Errorf("unknown RL algorithm %q", rlAlgo)
+		}
+		if md.kw.Iterations > 0 && md.kw.AlgorithmName() != rlAlgo {
+			return fmt.Errorf("model %s was trained with %s, not %s", rlModelFile,
+				md.kw.AlgorithmName(), rlAlgo)
+		}
+		md.kw.Algorithm = rlAlgo
+	}
+
+	if rlAlphaSchedule != "" {
+		s, err := rl.ParseSchedule(rlAlphaSchedule)
+		if err != nil {
+			return err
+		}
+		md.kw.AlphaSchedule = s
+	}
+
+	if rlAlphaCount {
+		md.kw.CountBasedAlpha = true
+	}
+
+	if rlAfterstates {
+		if md.kw.Iterations > 0 && !md.kw.Afterstates {
+			return fmt.Errorf("model %s was not trained on afterstates", rlModelFile)
+		}
+		md.kw.Afterstates = true
+	}
+
+	if rlExplore != "" {
+		if _, ok := rl.Explorations[rlExplore]; !ok {
+			return fmt.Errorf("unknown exploration policy %q", rlExplore)
+		}
+		md.kw.Exploration = rlExplore
+	}
+	if rlTempSchedule != "" {
+		s, err := rl.ParseSchedule(rlTempSchedule)
+		if err != nil {
+			return err
+		}
+		md.kw.TemperatureSchedule = s
+	}
+	if rlUCBFactor < 0 {
+		return fmt.Errorf("UCB weight must not be negative, not %g", rlUCBFactor)
+	}
+	if rlUCBFactor > 0 {
+		md.kw.UCBFactor = rlUCBFactor
+	}
+	if rlOptimisticValue != 0 {
+		md.kw.OptimisticValue = rlOptimisticValue
+	}
+
+	switch rlStore {
+	case "":
+	case "gob":
+		if err := md.kw.CloseStore(); err != nil {
+			return err
+		}
+	case "log":
+		if md.kw.Store != "log" {
+			// Start the store afresh from the values in memory
+			os.Remove(rlModelFile + ".values")
+			if err := md.kw.OpenStore(rlModelFile+".values", 0); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown value store %q", rlStore)
+	}
+	if rlMemoryCap < 0 {
+		return fmt.Errorf("memory cap must not be negative, not %d", rlMemoryCap)
+	}
+	if rlMemoryCap > 0 {
+		if err := md.kw.SetMemoryCap(rlMemoryCap); err != nil {
+			return err
+		}
+	}
+
+	if rlLambda < 0 || rlLambda > 1 {
+		return fmt.Errorf("lambda must be between 0 and 1, not %g", rlLambda)
+	}
+	if rlTraces != "replacing" && rlTraces != "accumulating" {
+		return fmt.Errorf("unknown eligibility traces %q", rlTraces)
+	}
+
+	if rlShaping != "" {
+		if rlNN {
+			return fmt.Errorf("reward shaping is only supported by the tabular agent")
+		}
+
+		var err error
+		if md.shaping, err = rl.ParseShaping(rlShaping); err != nil {
+			return err
+		}
+	}
+
+	if rlReplaySize < 0 || rlReplayBatch < 1 {
+		return fmt.Errorf("invalid replay buffer size %d or batch %d",
+			rlReplaySize, rlReplayBatch)
+	}
+	if rlReplaySize > 0 && (playerParams[1].Lambda > 0 || playerParams[2].Lambda > 0) {
+		return fmt.Errorf("eligibility traces need whole episodes and " +
+			"cannot be combined with experience replay")
+	}
+
+	if rlEpsilonSchedule != "" {
+		s, err := rl.ParseSchedule(rlEpsilonSchedule)
+		if err != nil {
+			return err
+		}
+		md.kw.EpsilonSchedule = s
+		if md.nn != nil {
+			md.nn.EpsilonSchedule = s
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"mnkagent/rl"
+)
+
+func TestModelsSnapshot(t *testing.T) {
+	md := &models{kw: &rl.RLAgentKnowledge{Values: map[string]float64{"a": 1}}}
+
+	s := md.snapshot()
+	opponent, err := s.Opponent(2)
+	if err != nil {
+		t.Fatalf("snapshot(): Unexpected error %v", err)
+	}
+
+	agent := opponent.(*rl.RLAgent)
+	if agent.Learning || agent.Knowledge().Values["a"] != 1 {
+		t.Errorf("snapshot(): Expected a frozen copy of the values, actual %v", agent.Knowledge().Values)
+	}
+	md.kw.Values["a"] = 2
+	if agent.Knowledge().Values["a"] != 1 {
+		t.Error("snapshot(): Expected the copy to be independent of the model")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"mnkagent/mnk"
+	"mnkagent/rl"
+)
+
+// trainingMonitor tracks a run for the Prometheus metrics endpoint
+type trainingMonitor struct {
+	start       time.Time
+	games       uint64
+	outcomes    [3]uint64 // Draws and wins of X and O
+	saves       uint64
+	saveSeconds float64
+	lastSave    float64
+
+	// Hyperparameters of the players as of their last game, by name and player
+	params map[string]map[int]float64
+
+	// The models played, whose size is reported
+	models *models
+
+	mu sync.Mutex
+}
+
+func newTrainingMonitor(md *models) *trainingMonitor {
+	return &trainingMonitor{
+		start:  time.Now(),
+		params: make(map[string]map[int]float64),
+		models: md,
+	}
+}
+
+// game records the winner of a game, zero for a draw, and the current
+// hyperparameters of the players
+func (tm *trainingMonitor) game(winner int, agents [3]mnk.Agent) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.games++
+	tm.outcomes[winner]++
+
+	for id, agent := range agents[1:] {
+		if agent == nil {
+			continue
+		}
+		if a, ok := agent.(interface{ CurrentLearningRate() float64 }); ok {
+			tm.param("learning_rate", id+1, a.CurrentLearningRate())
+		}
+		if a, ok := agent.(interface{ CurrentExplorationFactor() float64 }); ok {
+			tm.param("exploration_rate", id+1, a.CurrentExplorationFactor())
+		}
+		switch a := agent.(type) {
+		case *rl.RLAgent:
+			tm.param("discount_factor", id+1, a.DiscountFactor)
+			tm.param("lambda", id+1, a.Lambda)
+		case *rl.NNAgent:
+			tm.param("discount_factor", id+1, a.DiscountFactor)
+		}
+	}
+}
+
+// param sets a hyperparameter gauge; the monitor must be locked
+func (tm *trainingMonitor) param(name string, player int, v float64) {
+	if tm.params[name] == nil {
+		tm.params[name] = make(map[int]float64)
+	}
+	tm.params[name][player] = v
+}
+
+// saved records the duration of saving the models
+func (tm *trainingMonitor) saved(d time.Duration) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.saves++
+	tm.lastSave = d.Seconds()
+	tm.saveSeconds += tm.lastSave
+}
+
+// writeMetrics writes the metrics in the Prometheus text format
+func (tm *trainingMonitor) writeMetrics(w io.Writer) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP mnk_%s %s\n# TYPE mnk_%s %s\n", name, help, name, kind)
+	}
+
+	metric("uptime_seconds", "gauge", "Seconds since the run started.")
+	fmt.Fprintf(w, "mnk_uptime_seconds %g\n", time.Since(tm.start).Seconds())
+
+	metric("games_total", "counter", "Games played.")
+	fmt.Fprintf(w, "mnk_games_total %d\n", tm.games)
+
+	metric("game_outcomes_total", "counter", "Games played by outcome.")
+	for i, outcome := range [3]string{"draw", "x", "o"} {
+		fmt.Fprintf(w, "mnk_game_outcomes_total{outcome=%q} %d\n", outcome, tm.outcomes[i])
+	}
+
+	entries, iterations := tm.models.size()
+	metric("table_entries", "gauge", "Values of the RL table in memory.")
+	fmt.Fprintf(w, "mnk_table_entries %d\n", entries)
+	metric("model_iterations", "gauge", "Training iterations of the model.")
+	fmt.Fprintf(w, "mnk_model_iterations %d\n", iterations)
+
+	metric("saves_total", "counter", "Saves of the models.")
+	fmt.Fprintf(w, "mnk_saves_total %d\n", tm.saves)
+	metric("save_seconds_total", "counter", "Seconds spent saving the models.")
+	fmt.Fprintf(w, "mnk_save_seconds_total %g\n", tm.saveSeconds)
+	metric("last_save_seconds", "gauge", "Duration of the latest save of the models.")
+	fmt.Fprintf(w, "mnk_last_save_seconds %g\n", tm.lastSave)
+
+	var names []string
+	for name := range tm.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		metric(name, "gauge", "Current "+name+" hyperparameter of each player.")
+		for id := 1; id <= 2; id++ {
+			if v, ok := tm.params[name][id]; ok {
+				fmt.Fprintf(w, "mnk_%s{player=\"%d\"} %g\n", name, id, v)
+			}
+		}
+	}
+}
+
+// handler returns the metrics and profiling routes
+func (tm *trainingMonitor) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		tm.writeMetrics(w)
+	})
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// serve serves the metrics and pprof endpoints on addr in the background
+func (tm *trainingMonitor) serve(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Serving metrics on http://%s/metrics\n", ln.Addr())
+	go http.Serve(ln, tm.handler())
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mnkagent/agent"
+	"mnkagent/mnk"
+	"mnkagent/rl"
+)
+
+func TestTrainingMonitor(t *testing.T) {
+	md := &models{kw: new(rl.RLAgentKnowledge)}
+	tm := newTrainingMonitor(md)
+	rlAgent := rl.NewRLAgent(1, X, 3, 3, 3, md.kw, true)
+	rlAgent.LearningRate = 0.1
+	tm.game(1, [3]mnk.Agent{nil, rlAgent, agent.NewRandomAgent(2, O)})
+	tm.game(0, [3]mnk.Agent{nil, rlAgent, agent.NewRandomAgent(2, O)})
+	tm.saved(1500 * time.Millisecond)
+
+	srv := httptest.NewServer(tm.handler())
+	defer srv.Close()
+	res, err := srv.Client().Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: Unexpected error %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	for _, want := range []string{
+		"# TYPE mnk_games_total counter\nmnk_games_total 2\n",
+		`mnk_game_outcomes_total{outcome="x"} 1`,
+		`mnk_game_outcomes_total{outcome="draw"} 1`,
+		"mnk_last_save_seconds 1.5\n",
+		`mnk_learning_rate{player="1"} 0.1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("GET /metrics: Expected %q, actual\n%s", want, body)
+		}
+	}
+	if strings.Contains(string(body), `mnk_learning_rate{player="2"}`) {
+		t.Error("GET /metrics: Expected no learning rate of the random agent")
+	}
+
+	res, err = srv.Client().Get(srv.URL + "/debug/pprof/")
+	if err != nil || res.StatusCode != 200 {
+		t.Errorf("GET /debug/pprof/: Expected the pprof index, actual %v", err)
+	}
+}
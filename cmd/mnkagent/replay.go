@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"mnkagent/game"
+	"mnkagent/mnk"
+)
+
+// replayGames shows the recorded games on the board, one move every delay;
+// number selects a single game, starting at 1
+func replayGames(path string, number int, delay time.Duration) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	games, err := game.ReadRecords(file)
+	if err != nil {
+		return err
+	}
+	if number < 0 || number > len(games) {
+		return fmt.Errorf("replay: %s has %d games, not %d", path, len(games), number)
+	}
+
+	for i, g := range games {
+		if number > 0 && i+1 != number {
+			continue
+		}
+		if err = replayGame(i+1, g, delay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayGame shows a single recorded game
+func replayGame(number int, g game.Record, delay time.Duration) error {
+	b, err := mnk.NewMNKBoard(g.M, g.N, g.K)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Game %d: %s (%s) vs %s (%s)\n", number, g.Players[0], X, g.Players[1], O)
+	var sc screen
+	sc.display(b.GetState())
+
+	for _, mv := range g.Moves {
+		time.Sleep(delay)
+		if _, err = b.Act(mv.Player, mnk.MNKAction{X: mv.X, Y: mv.Y}); err != nil {
+			return fmt.Errorf("replay: game %d: %v", number, err)
+		}
+		sc.display(b.GetState())
+	}
+
+	if g.Winner == 0 {
+		fmt.Print("\nIt's a DRAW!\n")
+	} else {
+		fmt.Printf("\n%s won\n", signs[g.Winner])
+	}
+	fmt.Print("___________________________________\n\n")
+	return nil
+}
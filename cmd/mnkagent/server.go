@@ -0,0 +1,511 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mnkagent/mnk"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// Limits on games created through the server
+const (
+	serverMaxDimension = 25
+	serverHumanID      = 1
+	serverAgentID      = 2
+	serverFinishedTTL  = time.Minute      // How long finished games stay around
+	serverIdleTTL      = 30 * time.Minute // How long abandoned games stay around
+	serverMaxDepth     = 4                // Deepest minimax search of an opponent
+	serverMaxBudget    = 10000            // Largest MCTS budget of an opponent
+)
+
+// gameServer hosts concurrent games between web players and agents
+type gameServer struct {
+	mu          sync.Mutex
+	games       map[string]*serverGame
+	nextID      int
+	maxGames    int
+	finishedTTL time.Duration
+	idleTTL     time.Duration
+
+	// The models of the learning opponents
+	models *models
+}
+
+// serverGame is a single game, each with its own environment instance
+type serverGame struct {
+	mu     sync.Mutex
+	id     string
+	env    *mnk.MNKBoard
+	agent  mnk.Agent
+	spec   string
+	models *models // Saved once the agent learned from the game
+	turn   int
+	winner int
+	over   bool
+	last   time.Time // Time of the last move
+	subs   map[chan gameView]bool
+}
+
+// gameView is the JSON representation of a game sent to clients
+type gameView struct {
+	ID       string       `json:"id"`
+	M        int          `json:"m"`
+	N        int          `json:"n"`
+	K        int          `json:"k"`
+	Opponent string       `json:"opponent"`
+	Board    mnk.MNKState `json:"board"`
+	Turn     int          `json:"turn"`
+	Human    int          `json:"human"`
+	Over     bool         `json:"over"`
+	Winner   int          `json:"winner"`
+	Message  string       `json:"message,omitempty"`
+}
+
+type newGameRequest struct {
+	M        int    `json:"m"`
+	N        int    `json:"n"`
+	K        int    `json:"k"`
+	Opponent string `json:"opponent"`
+	First    string `json:"first"`
+}
+
+type moveRequest struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func newGameServer(md *models, maxGames int) *gameServer {
+	return &gameServer{
+		games:       make(map[string]*serverGame),
+		maxGames:    maxGames,
+		finishedTTL: serverFinishedTTL,
+		idleTTL:     serverIdleTTL,
+		models:      md,
+	}
+}
+
+// serve starts the HTTP server on the given address, with opponents learning
+// into the models
+func serve(md *models, addr string) error {
+	gs := newGameServer(md, serveMaxGames)
+	fmt.Printf("Serving games on http://%s/\n", addr)
+	return http.ListenAndServe(addr, gs.handler())
+}
+
+// handler returns the HTTP routes of the server
+func (gs *gameServer) handler() http.Handler {
+	static, _ := fs.Sub(webFiles, "web")
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServer(http.FS(static)))
+	mux.HandleFunc("POST /api/games", gs.handleCreate)
+	mux.HandleFunc("GET /api/games/{id}", gs.handleGet)
+	mux.HandleFunc("DELETE /api/games/{id}", gs.handleDelete)
+	mux.HandleFunc("POST /api/games/{id}/moves", gs.handleMove)
+	mux.HandleFunc("GET /api/games/{id}/ws", gs.handleWebSocket)
+	return mux
+}
+
+func (gs *gameServer) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req = newGameRequest{M: 3, N: 3, K: 3, Opponent: "rl", First: "human"}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	g, err := gs.create(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	g.mu.Lock()
+	view := g.view("")
+	g.mu.Unlock()
+	writeJSON(w, http.StatusCreated, view)
+}
+
+func (gs *gameServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	g := gs.lookup(r.PathValue("id"))
+	if g == nil {
+		writeError(w, http.StatusNotFound, errors.New("server: game not found"))
+		return
+	}
+
+	g.mu.Lock()
+	view := g.view("")
+	g.mu.Unlock()
+	writeJSON(w, http.StatusOK, view)
+}
+
+func (gs *gameServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	gs.mu.Lock()
+	g, ok := gs.games[r.PathValue("id")]
+	delete(gs.games, r.PathValue("id"))
+	gs.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("server: game not found"))
+		return
+	}
+
+	g.mu.Lock()
+	g.close()
+	g.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (gs *gameServer) handleMove(w http.ResponseWriter, r *http.Request) {
+	g := gs.lookup(r.PathValue("id"))
+	if g == nil {
+		writeError(w, http.StatusNotFound, errors.New("server: game not found"))
+		return
+	}
+
+	var req moveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	view, err := g.move(mnk.MNKAction{X: req.X, Y: req.Y})
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+func (gs *gameServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	g := gs.lookup(r.PathValue("id"))
+	if g == nil {
+		writeError(w, http.StatusNotFound, errors.New("server: game not found"))
+		return
+	}
+
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	updates := g.subscribe()
+	defer g.unsubscribe(updates)
+
+	// Stream state updates
+	go func() {
+		for view := range updates {
+			p, _ := json.Marshal(view)
+			if ws.WriteMessage(p) != nil {
+				return
+			}
+		}
+		ws.Close()
+	}()
+
+	// Accept moves
+	for {
+		p, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var req moveRequest
+		if err = json.Unmarshal(p, &req); err != nil {
+			g.publish(err.Error())
+			continue
+		}
+		if _, err = g.move(mnk.MNKAction{X: req.X, Y: req.Y}); err != nil {
+			g.publish(err.Error())
+		}
+	}
+}
+
+// create registers a new game and lets the agent open if requested
+func (gs *gameServer) create(req newGameRequest) (*serverGame, error) {
+	if req.M < 1 || req.N < 1 || req.K < 1 ||
+		req.M > serverMaxDimension || req.N > serverMaxDimension {
+		return nil, fmt.Errorf("server: board dimensions must be between 1 and %d",
+			serverMaxDimension)
+	}
+	if err := checkOpponent(req.Opponent); err != nil {
+		return nil, err
+	}
+	if (req.Opponent == "rl" || req.Opponent == "nn") &&
+		(req.M != m || req.N != n || req.K != k) {
+		// The shared model only learns games of its own size
+		return nil, fmt.Errorf("server: the %s opponent only plays %d,%d,%d games",
+			req.Opponent, m, n, k)
+	}
+
+	env, err := mnk.NewMNKBoard(req.M, req.N, req.K)
+	if err != nil {
+		return nil, err
+	}
+
+	agent, err := gs.models.newAgent(req.Opponent, serverAgentID, "O", req.M, req.N, req.K)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &serverGame{
+		env:    env,
+		agent:  agent,
+		spec:   req.Opponent,
+		models: gs.models,
+		turn:   serverHumanID,
+		last:   time.Now(),
+		subs:   make(map[chan gameView]bool),
+	}
+
+	switch req.First {
+	case "", "human":
+	case "agent":
+		g.turn = serverAgentID
+	default:
+		return nil, fmt.Errorf("server: unknown first player %q", req.First)
+	}
+
+	// The game is only registered once the agent has opened
+	if g.turn == serverAgentID {
+		if err = g.agentMove(); err != nil {
+			return nil, err
+		}
+		if g.over {
+			g.models.saveLearner(g.spec)
+		}
+	}
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.reap(time.Now())
+	if len(gs.games) >= gs.maxGames {
+		return nil, errors.New("server: too many games in progress")
+	}
+	gs.nextID++
+	g.id = strconv.Itoa(gs.nextID)
+	gs.games[g.id] = g
+
+	return g, nil
+}
+
+// checkOpponent accepts the agents clients may play against: no models
+// loaded from files, and searches of bounded cost
+func checkOpponent(spec string) error {
+	name, param, _ := strings.Cut(spec, ":")
+
+	var limit int
+	switch name {
+	case "random", "rl", "nn":
+		if param == "" {
+			return nil
+		}
+	case "minimax":
+		limit = serverMaxDepth
+	case "mcts":
+		limit = serverMaxBudget
+	}
+
+	if limit > 0 {
+		if v, err := strconv.Atoi(param); err == nil && v >= 1 && v <= limit {
+			return nil
+		}
+		return fmt.Errorf("server: %s opponents take 1 to %d, not %q", name, limit, param)
+	}
+	return fmt.Errorf("server: unsupported opponent %q", spec)
+}
+
+// reap drops the games that ended or were abandoned a while ago, and the
+// finished ones right away when the server is full; the server must be locked
+func (gs *gameServer) reap(now time.Time) {
+	full := len(gs.games) >= gs.maxGames
+	for id, g := range gs.games {
+		g.mu.Lock()
+		idle := now.Sub(g.last)
+		if idle > gs.idleTTL || g.over && (full || idle > gs.finishedTTL) {
+			g.close()
+			delete(gs.games, id)
+		}
+		g.mu.Unlock()
+	}
+}
+
+func (gs *gameServer) lookup(id string) *serverGame {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	return gs.games[id]
+}
+
+// move plays the human's action followed by the agent's reply
+func (g *serverGame) move(action mnk.MNKAction) (gameView, error) {
+	g.mu.Lock()
+	view, err := g.play(action)
+	ended := g.over && err == nil
+	g.mu.Unlock()
+
+	// Saving may take a while, the game is not kept waiting
+	if ended {
+		g.models.saveLearner(g.spec)
+	}
+	return view, err
+}
+
+// play makes the move of the human and the agent; the game must be locked
+func (g *serverGame) play(action mnk.MNKAction) (gameView, error) {
+	if g.over {
+		return g.view(""), errors.New("server: game is over")
+	}
+	if g.turn != serverHumanID {
+		return g.view(""), errors.New("server: not your turn")
+	}
+
+	if _, err := g.env.Act(serverHumanID, action); err != nil {
+		return g.view(""), err
+	}
+	g.last = time.Now()
+	g.evaluate(serverHumanID, action)
+	g.broadcast("")
+
+	if !g.over {
+		if err := g.agentMove(); err != nil {
+			return g.view(""), err
+		}
+	}
+
+	return g.view(""), nil
+}
+
+// agentMove asks the opponent agent for its move; the game must be locked
+func (g *serverGame) agentMove() error {
+	action, err := g.agent.FetchMove(g.env.GetState(),
+		g.env.GetPotentialActions(serverAgentID))
+	if err != nil {
+		return err
+	}
+
+	if _, err = g.env.Act(serverAgentID, action); err != nil {
+		return err
+	}
+	g.evaluate(serverAgentID, action)
+	g.broadcast(g.agent.FetchMessage())
+	return nil
+}
+
+// evaluate updates the game status after the given action
+func (g *serverGame) evaluate(turn int, action mnk.Action) {
+	switch result := g.env.EvaluateAction(turn, action); result {
+	case 0: // The game goes on
+		g.turn = mnk.Opponent(turn)
+		return
+	case -1: // Draw
+		g.winner = -1
+	default: // Current player won
+		g.winner = turn
+	}
+
+	g.over = true
+	g.agent.GameOver(g.env.GetState())
+}
+
+// saveLearner saves the shared model the opponent of a finished game learned
+// into
+func (md *models) saveLearner(spec string) {
+	if rlNoLearn {
+		return
+	}
+
+	switch spec {
+	case "rl":
+		md.kw.SaveToFile(rlModelFile)
+	case "nn":
+		md.nn.SaveToFile(rlModelFile + ".nn")
+	}
+}
+
+// close ends the subscriptions to the game; the game must be locked
+func (g *serverGame) close() {
+	for c := range g.subs {
+		close(c)
+		delete(g.subs, c)
+	}
+}
+
+// view returns the client representation; the game must be locked
+func (g *serverGame) view(message string) gameView {
+	m, n, k := g.env.Size()
+	return gameView{
+		ID:       g.id,
+		M:        m,
+		N:        n,
+		K:        k,
+		Opponent: g.spec,
+		Board:    g.env.GetState().(mnk.MNKState),
+		Turn:     g.turn,
+		Human:    serverHumanID,
+		Over:     g.over,
+		Winner:   g.winner,
+		Message:  message,
+	}
+}
+
+func (g *serverGame) subscribe() chan gameView {
+	c := make(chan gameView, 1)
+
+	g.mu.Lock()
+	g.subs[c] = true
+	c <- g.view("")
+	g.mu.Unlock()
+
+	return c
+}
+
+func (g *serverGame) unsubscribe(c chan gameView) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.subs[c] {
+		delete(g.subs, c)
+		close(c)
+	}
+}
+
+// publish sends a message along with the current state to subscribers
+func (g *serverGame) publish(message string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.broadcast(message)
+}
+
+// broadcast sends the current state to subscribers; the game must be locked
+func (g *serverGame) broadcast(message string) {
+	view := g.view(message)
+	for c := range g.subs {
+		// Only the latest state matters to slow subscribers
+		select {
+		case <-c:
+		default:
+		}
+		c <- view
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mnkagent/rl"
+)
+
+func TestServerGame(t *testing.T) {
+	ts := httptest.NewServer(newGameServer(&models{kw: new(rl.RLAgentKnowledge)}, 10).handler())
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/games", "application/json",
+		strings.NewReader(`{"m":3,"n":3,"k":3,"opponent":"random"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var view gameView
+	json.NewDecoder(res.Body).Decode(&view)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated || view.Turn != serverHumanID {
+		t.Fatalf("POST /api/games: Expected a new game on the human's turn, "+
+			"actual %d %+v", res.StatusCode, view)
+	}
+
+	// Play the first free cell until the game ends
+	for moves := 0; !view.Over; moves++ {
+		if moves > 5 {
+			t.Fatalf("Game did not end after %d moves: %+v", moves, view)
+		}
+
+		var body string
+	search:
+		for y := range view.Board {
+			for x := range view.Board[y] {
+				if view.Board[y][x] == 0 {
+					body = fmt.Sprintf(`{"x":%d,"y":%d}`, x, y)
+					break search
+				}
+			}
+		}
+
+		res, err = http.Post(ts.URL+"/api/games/"+view.ID+"/moves",
+			"application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.NewDecoder(res.Body).Decode(&view)
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("POST moves %s: Expected %d, actual %d",
+				body, http.StatusOK, res.StatusCode)
+		}
+	}
+
+	res, err = http.Post(ts.URL+"/api/games/"+view.ID+"/moves",
+		"application/json", strings.NewReader(`{"x":0,"y":0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusConflict {
+		t.Errorf("POST moves after game over: Expected %d, actual %d",
+			http.StatusConflict, res.StatusCode)
+	}
+}
+
+func TestServerReapsGames(t *testing.T) {
+	gs := newGameServer(&models{kw: new(rl.RLAgentKnowledge)}, 1)
+	req := newGameRequest{M: 3, N: 3, K: 3, Opponent: "random"}
+
+	g, err := gs.create(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = gs.create(req); err == nil {
+		t.Fatal("create(): Expected an error with too many games in progress")
+	}
+
+	// Finished games make room for new ones
+	g.over = true
+	if g, err = gs.create(req); err != nil {
+		t.Fatalf("create(): Expected the finished game to be dropped, actual %v", err)
+	}
+	if gs.lookup("1") != nil {
+		t.Error("create(): Expected the finished game to be gone")
+	}
+
+	// So do abandoned ones
+	g.last = time.Now().Add(-2 * serverIdleTTL)
+	if _, err = gs.create(req); err != nil {
+		t.Errorf("create(): Expected the idle game to be dropped, actual %v", err)
+	}
+}
+
+func TestServerModelSize(t *testing.T) {
+	gs := newGameServer(&models{kw: new(rl.RLAgentKnowledge)}, 1)
+	_, err := gs.create(newGameRequest{M: m + 1, N: n, K: k, Opponent: "rl"})
+	if err == nil {
+		t.Errorf("create(): Expected an error for a %d,%d,%d rl game", m+1, n, k)
+	}
+	if len(gs.games) != 0 {
+		t.Errorf("create(): Expected no game, actual %d", len(gs.games))
+	}
+}
+
+func TestServerOpponents(t *testing.T) {
+	gs := newGameServer(&models{kw: new(rl.RLAgentKnowledge)}, 10)
+	for _, spec := range []string{"rl:/etc/passwd", "nn:/etc/passwd", "minimax:100",
+		"mcts:1000000", "minimax:x", "human", "bogus"} {
+		if _, err := gs.create(newGameRequest{M: m, N: n, K: k, Opponent: spec}); err == nil {
+			t.Errorf("create(): Expected an error for opponent %q", spec)
+		}
+	}
+	if len(specModels) != 0 {
+		t.Errorf("create(): Expected no model loaded, actual %d", len(specModels))
+	}
+
+	for _, spec := range []string{"random", "rl", "minimax:2", "mcts:1000"} {
+		if _, err := gs.create(newGameRequest{M: m, N: n, K: k, Opponent: spec}); err != nil {
+			t.Errorf("create(): Expected opponent %q to be accepted, actual %v", spec, err)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"mnkagent/rl"
+	"mnkagent/train"
+)
+
+// sweepJob is a single configuration of a hyperparameter sweep
+type sweepJob struct {
+	Index           int
+	Alpha           string
+	Gamma           string
+	Epsilon         string
+	AlphaSchedule   string
+	EpsilonSchedule string
+
+	Dir    string
+	Result train.Evaluation
+	Err    error
+}
+
+// args returns the flags setting the job's hyperparameters for both players
+func (j *sweepJob) args() (args []string) {
+	for id := 1; id <= 2; id++ {
+		for name, v := range map[string]string{
+			"alpha": j.Alpha, "gamma": j.Gamma, "epsilon": j.Epsilon,
+		} {
+			if v != "" {
+				args = append(args, "-"+playerFlag(id, name)+"="+v)
+			}
+		}
+	}
+	if j.AlphaSchedule != "" {
+		args = append(args, "-rl-alpha-schedule="+j.AlphaSchedule)
+	}
+	if j.EpsilonSchedule != "" {
+		args = append(args, "-rl-epsilon-schedule="+j.EpsilonSchedule)
+	}
+	sort.Strings(args)
+	return
+}
+
+// sweepFlags are the flags a sweep sets on its jobs itself
+var sweepFlags = map[string]bool{
+	"config": true, "config-dump": true, "rl-model": true, "rounds": true,
+	"no-display": true, "seed": true, "rl-eval-every": true,
+	"rl-eval-games": true, "rl-eval-opponent": true, "rl-eval-log": true,
+	"metrics-log": true, "metrics-addr": true,
+}
+
+// Flags of the sweep command
+var (
+	sweepAlpha            string
+	sweepGamma            string
+	sweepEpsilon          string
+	sweepAlphaSchedules   string
+	sweepEpsilonSchedules string
+	sweepWorkers          = runtime.NumCPU()
+	sweepDir              = "sweep"
+)
+
+// sweepCommandFlags are the flags of a sweep: the grid, and the training
+// flags passed on to its jobs
+func sweepCommandFlags(fs *flag.FlagSet) {
+	trainingFlags(fs)
+
+	fs.StringVar(&sweepAlpha, "alpha", sweepAlpha, "Learning rates, as a "+
+		"list 0.1,0.2 or a range start:stop:step")
+	fs.StringVar(&sweepGamma, "gamma", sweepGamma, "Discount factors, as a "+
+		"list or a range")
+	fs.StringVar(&sweepEpsilon, "epsilon", sweepEpsilon, "Exploration rates, "+
+		"as a list or a range")
+	fs.StringVar(&sweepAlphaSchedules, "alpha-schedule", sweepAlphaSchedules,
+		"Semicolon separated learning rate schedules")
+	fs.StringVar(&sweepEpsilonSchedules, "epsilon-schedule", sweepEpsilonSchedules,
+		"Semicolon separated exploration schedules")
+	fs.IntVar(&sweepWorkers, "workers", sweepWorkers, "Jobs run in parallel")
+	fs.StringVar(&sweepDir, "dir", sweepDir, "Directory of the models and "+
+		"results of the jobs")
+}
+
+// runSweep trains a model for every combination of the given
+// hyperparameters, each in a process and directory of its own, evaluates
+// them against a baseline and ranks them by win rate
+func runSweep(fs *flag.FlagSet) error {
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+	if rounds <= 0 || rlEvalGames < 1 || sweepWorkers < 1 {
+		return fmt.Errorf("sweep: invalid rounds %d, evaluation games %d or workers %d",
+			rounds, rlEvalGames, sweepWorkers)
+	}
+
+	// Only the jobs load models; the baseline is checked against empty ones
+	md := &models{kw: new(rl.RLAgentKnowledge)}
+	if _, err := md.newAgent(rlEvalOpponent, 2, O, m, n, k); err != nil || rlEvalOpponent == "human" {
+		return fmt.Errorf("sweep: invalid baseline %q", rlEvalOpponent)
+	}
+
+	var grid [5][]string
+	var err error
+	for i, spec := range []string{sweepAlpha, sweepGamma, sweepEpsilon} {
+		if grid[i], err = parseSweepValues(spec); err != nil {
+			return err
+		}
+	}
+	for i, spec := range []string{sweepAlphaSchedules, sweepEpsilonSchedules} {
+		if grid[3+i], err = parseSweepSchedules(spec); err != nil {
+			return err
+		}
+	}
+	jobs := sweepGrid(grid)
+
+	if err = os.MkdirAll(sweepDir, 0755); err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	// Jobs share the settings of the sweep, including its seed
+	var base = []string{"train", "-no-display",
+		"-seed=" + strconv.FormatInt(seed, 10),
+		"-rounds=" + strconv.Itoa(rounds),
+		"-rl-eval-every=" + strconv.Itoa(rounds),
+		"-rl-eval-games=" + strconv.Itoa(rlEvalGames),
+		"-rl-eval-opponent=" + rlEvalOpponent}
+	trainSet := findCommand("train").flagSet(flag.ContinueOnError)
+	fs.Visit(func(f *flag.Flag) {
+		if !sweepFlags[f.Name] && trainSet.Lookup(f.Name) != nil {
+			base = append(base, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+
+	fmt.Printf("Sweeping %d configurations with %d workers...\n", len(jobs), sweepWorkers)
+
+	var wg sync.WaitGroup
+	var queue = make(chan *sweepJob)
+	for w := 0; w < sweepWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				j.Dir = filepath.Join(sweepDir, fmt.Sprintf("job-%03d", j.Index))
+				j.Result, j.Err = runSweepJob(exe, base, j)
+				if j.Err != nil {
+					fmt.Printf("Job %d failed: %v\n", j.Index, j.Err)
+				} else {
+					fmt.Printf("Job %d done: win rate %.2f\n", j.Index, j.Result.WinRate())
+				}
+			}
+		}()
+	}
+	for _, j := range jobs {
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
+
+	rankSweep(jobs)
+	printSweep(os.Stdout, jobs)
+
+	results := filepath.Join(sweepDir, "results.csv")
+	file, err := os.Create(results)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = writeSweep(file, jobs); err != nil {
+		return err
+	}
+	fmt.Println("Results written to", results)
+	return nil
+}
+
+// runSweepJob trains and evaluates a single configuration in a process of
+// its own
+func runSweepJob(exe string, base []string, j *sweepJob) (r train.Evaluation, err error) {
+	if err = os.MkdirAll(j.Dir, 0755); err != nil {
+		return
+	}
+
+	// Start over rather than resume a previous sweep
+	model := filepath.Join(j.Dir, "rl.kw")
+	log := filepath.Join(j.Dir, "eval.csv")
+	for _, path := range []string{model, model + ".nn", model + ".values", model + ".replay", log} {
+		os.Remove(path)
+	}
+
+	args := append(append([]string{}, base...), j.args()...)
+	args = append(args, "-rl-model="+model, "-rl-eval-log="+log)
+
+	cmd := exec.Command(exe, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return r, fmt.Errorf("%v: %s", err, lastLine(string(output)))
+	}
+
+	file, err := os.Open(log)
+	if err != nil {
+		return r, fmt.Errorf("no evaluation: %s", lastLine(string(output)))
+	}
+	defer file.Close()
+	return train.ReadEvaluation(file)
+}
+
+// lastLine returns the last non-empty line of the output of a job
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// parseSweepValues parses a list of values such as 0.1,0.2 or a range such
+// as 0.1:0.5:0.1, stop included
+func parseSweepValues(spec string) ([]string, error) {
+	if spec == "" {
+		return []string{""}, nil
+	}
+
+	if parts := strings.Split(spec, ":"); len(parts) == 3 {
+		var bounds [3]float64
+		for i, p := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return nil, fmt.Errorf("sweep: invalid range %q", spec)
+			}
+			bounds[i] = v
+		}
+		start, stop, step := bounds[0], bounds[1], bounds[2]
+		if step <= 0 || stop < start {
+			return nil, fmt.Errorf("sweep: invalid range %q", spec)
+		}
+
+		var values []string
+		for i := 0; i <= int(math.Floor((stop-start)/step+1e-9)); i++ {
+			v := math.Round((start+float64(i)*step)*1e9) / 1e9
+			values = append(values, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		return values, nil
+	}
+
+	var values []string
+	for _, p := range strings.Split(spec, ",") {
+		p = strings.TrimSpace(p)
+		if _, err := strconv.ParseFloat(p, 64); err != nil {
+			return nil, fmt.Errorf("sweep: invalid value %q", p)
+		}
+		values = append(values, p)
+	}
+	return values, nil
+}
+
+// parseSweepSchedules parses semicolon separated decay schedules
+func parseSweepSchedules(spec string) ([]string, error) {
+	if spec == "" {
+		return []string{""}, nil
+	}
+
+	var schedules []string
+	for _, s := range strings.Split(spec, ";") {
+		s = strings.TrimSpace(s)
+		if _, err := rl.ParseSchedule(s); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, nil
+}
+
+// sweepGrid returns a job for every combination of alphas, gammas,
+// epsilons, alpha schedules and epsilon schedules
+func sweepGrid(grid [5][]string) (jobs []*sweepJob) {
+	for _, alpha := range grid[0] {
+		for _, gamma := range grid[1] {
+			for _, epsilon := range grid[2] {
+				for _, as := range grid[3] {
+					for _, es := range grid[4] {
+						jobs = append(jobs, &sweepJob{
+							Index:           len(jobs) + 1,
+							Alpha:           alpha,
+							Gamma:           gamma,
+							Epsilon:         epsilon,
+							AlphaSchedule:   as,
+							EpsilonSchedule: es,
+						})
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// rankSweep orders jobs by win rate, then by fewest losses; failed jobs last
+func rankSweep(jobs []*sweepJob) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		a, b := jobs[i], jobs[j]
+		if (a.Err == nil) != (b.Err == nil) {
+			return a.Err == nil
+		}
+		if a.Result.WinRate() != b.Result.WinRate() {
+			return a.Result.WinRate() > b.Result.WinRate()
+		}
+		return a.Result.Losses < b.Result.Losses
+	})
+}
+
+// printSweep prints the ranked results table
+func printSweep(w io.Writer, jobs []*sweepJob) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Rank\tJob\tAlpha\tGamma\tEpsilon\tAlpha schedule\t"+
+		"Epsilon schedule\tWins\tDraws\tLosses\tWin rate")
+	for i, j := range jobs {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\t%s\t", i+1, j.Index,
+			orDefault(j.Alpha), orDefault(j.Gamma), orDefault(j.Epsilon),
+			orDefault(j.AlphaSchedule), orDefault(j.EpsilonSchedule))
+		if j.Err != nil {
+			fmt.Fprintln(tw, "-\t-\t-\tfailed")
+			continue
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%.4f\n", j.Result.Wins, j.Result.Draws,
+			j.Result.Losses, j.Result.WinRate())
+	}
+	tw.Flush()
+}
+
+// writeSweep writes the ranked results as CSV
+func writeSweep(w io.Writer, jobs []*sweepJob) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"rank", "job", "alpha", "gamma", "epsilon",
+		"alpha_schedule", "epsilon_schedule", "games", "wins", "draws",
+		"losses", "win_rate", "error", "dir"})
+	for i, j := range jobs {
+		var errText string
+		if j.Err != nil {
+			errText = j.Err.Error()
+		}
+		cw.Write([]string{
+			strconv.Itoa(i + 1),
+			strconv.Itoa(j.Index),
+			j.Alpha, j.Gamma, j.Epsilon, j.AlphaSchedule, j.EpsilonSchedule,
+			strconv.Itoa(j.Result.Games),
+			strconv.Itoa(j.Result.Wins),
+			strconv.Itoa(j.Result.Draws),
+			strconv.Itoa(j.Result.Losses),
+			fmt.Sprintf("%.4f", j.Result.WinRate()),
+			errText,
+			j.Dir,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// orDefault shows an unset hyperparameter
+func orDefault(v string) string {
+	if v == "" {
+		return "default"
+	}
+	return v
+}
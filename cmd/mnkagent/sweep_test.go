@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"mnkagent/train"
+)
+
+func TestParseSweepValues(t *testing.T) {
+	for spec, want := range map[string][]string{
+		"":            {""},
+		"0.1, 0.2":    {"0.1", "0.2"},
+		"0.1:0.3:0.1": {"0.1", "0.2", "0.3"},
+		"0:1:0.25":    {"0", "0.25", "0.5", "0.75", "1"},
+	} {
+		values, err := parseSweepValues(spec)
+		if err != nil || !slices.Equal(values, want) {
+			t.Errorf("parseSweepValues(%q): Expected %v, actual %v (%v)", spec, want, values, err)
+		}
+	}
+
+	for _, spec := range []string{"0.1,x", "0.3:0.1:0.1", "0:1:0"} {
+		if _, err := parseSweepValues(spec); err == nil {
+			t.Errorf("parseSweepValues(%q): Expected an error", spec)
+		}
+	}
+}
+
+func TestSweepGridAndRanking(t *testing.T) {
+	jobs := sweepGrid([5][]string{{"0.1", "0.2"}, {""}, {"0.1", "0.2", "0.3"}, {""}, {""}})
+	if len(jobs) != 6 || jobs[5].Alpha != "0.2" || jobs[5].Epsilon != "0.3" {
+		t.Fatalf("sweepGrid(): Expected 6 jobs ending with alpha 0.2 and epsilon 0.3, actual %d", len(jobs))
+	}
+	if args := strings.Join(jobs[0].args(), " "); args != "-p1-alpha=0.1 -p1-epsilon=0.1 -p2-alpha=0.1 -p2-epsilon=0.1" {
+		t.Errorf("args(): Expected the hyperparameters of both players, actual %s", args)
+	}
+
+	r := train.Evaluation{Games: 10, Wins: 7, Draws: 1, Losses: 2}
+	jobs[0].Err = errors.New("failed")
+	jobs[1].Result = r
+	jobs[2].Result = train.Evaluation{Games: 10, Wins: 7, Losses: 3}
+	rankSweep(jobs)
+	if jobs[0].Index != 2 || jobs[1].Index != 3 || jobs[5].Index != 1 {
+		t.Errorf("rankSweep(): Expected jobs 2 and 3 first and the failed job last, actual %d, %d and %d",
+			jobs[0].Index, jobs[1].Index, jobs[5].Index)
+	}
+}
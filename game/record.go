@@ -0,0 +1,125 @@
+package game
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"mnkagent/mnk"
+)
+
+// Record is a finished game, stored as a line of JSON
+type Record struct {
+	M       int       `json:"m"`
+	N       int       `json:"n"`
+	K       int       `json:"k"`
+	Seed    int64     `json:"seed"`
+	Players [2]string `json:"players"` // X and O
+	Moves   []Move    `json:"moves"`
+	Winner  int       `json:"winner"` // Zero for a draw
+}
+
+// Move is a recorded move of a game
+type Move struct {
+	Player int `json:"player"`
+	X      int `json:"x"`
+	Y      int `json:"y"`
+}
+
+// Recorder appends the games it observes to a file; a nil recorder records
+// nothing
+type Recorder struct {
+	file    *os.File
+	seed    int64
+	players [2]string
+	game    *Record
+	err     error // First failed write
+}
+
+// NewRecorder appends to the file at path the games of a run with the given
+// seed
+func NewRecorder(path string, seed int64) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: file, seed: seed}, nil
+}
+
+// Seat names the agents of the following games
+func (r *Recorder) Seat(x, o string) {
+	if r != nil {
+		r.players = [2]string{x, o}
+	}
+}
+
+// Started begins recording a game on env, which must be an *mnk.MNKBoard
+func (r *Recorder) Started(env mnk.Environment) {
+	if r == nil {
+		return
+	}
+	m, n, k := env.(*mnk.MNKBoard).Size()
+	r.game = &Record{M: m, N: n, K: k, Seed: r.seed, Players: r.players}
+}
+
+// Moved records a move of the current game
+func (r *Recorder) Moved(_ mnk.Environment, player int, action mnk.Action) {
+	if r == nil || r.game == nil {
+		return
+	}
+	a := action.GetParams().(mnk.MNKAction)
+	r.game.Moves = append(r.game.Moves, Move{player, a.X, a.Y})
+}
+
+func (r *Recorder) Rejected(int, error) {}
+
+// Ended writes the current game with its winner, zero for a draw
+func (r *Recorder) Ended(winner int, _ bool) {
+	if r == nil || r.game == nil {
+		return
+	}
+	r.game.Winner = winner
+	if err := json.NewEncoder(r.file).Encode(r.game); err != nil && r.err == nil {
+		r.err = err
+	}
+	r.game = nil
+}
+
+// Err returns the first error writing a game, and clears it
+func (r *Recorder) Err() (err error) {
+	if r != nil {
+		err, r.err = r.err, nil
+	}
+	return
+}
+
+// Close releases the file, reporting a pending write error first
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	err := r.Err()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadRecords reads the games of a record file
+func ReadRecords(r io.Reader) (games []Record, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var g Record
+		if err = json.Unmarshal(scanner.Bytes(), &g); err != nil {
+			return nil, fmt.Errorf("replay: line %d: %v", line, err)
+		}
+		games = append(games, g)
+	}
+	return games, scanner.Err()
+}
@@ -0,0 +1,57 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mnkagent/mnk"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+	r, err := NewRecorder(path, 7)
+	if err != nil {
+		t.Fatalf("NewRecorder(): Unexpected error %v", err)
+	}
+
+	env, _ := mnk.NewMNKBoard(4, 3, 3)
+	x := &scriptedAgent{moves: []mnk.MNKAction{{X: 0, Y: 0}}}
+	o := &scriptedAgent{moves: []mnk.MNKAction{{X: 1, Y: 2}}}
+	obs := new(countingObserver)
+
+	// X resigns once out of moves
+	r.Seat("random", "minimax:1")
+	if winner, _ := Round(env, [3]mnk.Agent{nil, x, o}, 1, Observers(r, obs)); winner != 2 || obs.winner != 2 {
+		t.Fatalf("Round(): Expected O to win for both observers, actual %d and %d", winner, obs.winner)
+	}
+	r.Started(env)
+	r.Ended(0, false)
+	if err = r.Close(); err != nil {
+		t.Errorf("Close(): Unexpected error %v", err)
+	}
+
+	// A nil recorder records nothing
+	var none *Recorder
+	none.Started(env)
+	none.Moved(env, 1, mnk.MNKAction{})
+	none.Ended(1, false)
+	if err = none.Close(); err != nil {
+		t.Errorf("Close(): Expected no error of a nil recorder, actual %v", err)
+	}
+
+	file, _ := os.Open(path)
+	defer file.Close()
+	games, err := ReadRecords(file)
+	if err != nil || len(games) != 2 {
+		t.Fatalf("ReadRecords(): Expected 2 games, actual %d (%v)", len(games), err)
+	}
+	g := games[0]
+	if g.Players != [2]string{"random", "minimax:1"} || g.Winner != 2 || g.M != 4 || g.N != 3 ||
+		g.Seed != 7 || len(g.Moves) != 2 || g.Moves[1] != (Move{Player: 2, X: 1, Y: 2}) {
+		t.Errorf("ReadRecords(): Expected the recorded game, actual %+v", g)
+	}
+	if games[1].Winner != 0 || len(games[1].Moves) != 0 {
+		t.Errorf("ReadRecords(): Expected an empty draw, actual %+v", games[1])
+	}
+}
@@ -0,0 +1,111 @@
+// Package game plays rounds of m,n,k-games between agents and records them
+package game
+
+import "mnkagent/mnk"
+
+// Observer follows the progress of a round, e.g. to display or record it
+type Observer interface {
+	// Started is called once the environment was reset
+	Started(env mnk.Environment)
+
+	// Moved is called after every accepted move
+	Moved(env mnk.Environment, player int, action mnk.Action)
+
+	// Rejected is called when the environment refuses a move; the player is
+	// asked again
+	Rejected(player int, err error)
+
+	// Ended is called with the winner's id, zero for a draw, and whether the
+	// other player resigned
+	Ended(winner int, resigned bool)
+}
+
+// Round resets env and plays a game on it between agents 1 and 2, turn
+// moving first, and returns the winner's id, zero for a draw. Both agents are
+// shown the final state, also when an agent fails to move and the round ends
+// with its error. The observer may be nil.
+func Round(env mnk.Environment, agents [3]mnk.Agent, turn int, obs Observer) (int, error) {
+	env.Reset()
+	if obs == nil {
+		obs = nopObserver{}
+	}
+	obs.Started(env)
+
+	// Who starts the game if not specified
+	if turn == 0 {
+		turn = 1
+	}
+
+	end := func(winner int, resigned bool) (int, error) {
+		obs.Ended(winner, resigned)
+		agents[1].GameOver(env.GetState())
+		agents[2].GameOver(env.GetState())
+		return winner, nil
+	}
+
+	for {
+		action, err := agents[turn].FetchMove(env.GetState(), env.GetPotentialActions(turn))
+		if err == mnk.ErrResign { // Current player gave up
+			return end(mnk.Opponent(turn), true)
+		} else if err != nil {
+			// The agents start afresh with the next round
+			agents[1].GameOver(env.GetState())
+			agents[2].GameOver(env.GetState())
+			return 0, err
+		}
+
+		if _, err = env.Act(turn, action); err != nil {
+			obs.Rejected(turn, err)
+			continue
+		}
+		obs.Moved(env, turn, action)
+
+		switch env.EvaluateAction(turn, action) {
+		case 0: // The game goes on
+			turn = mnk.Opponent(turn)
+		case -1: // Draw
+			return end(0, false)
+		default: // Current player won
+			return end(turn, false)
+		}
+	}
+}
+
+// nopObserver ignores the progress of a round
+type nopObserver struct{}
+
+func (nopObserver) Started(mnk.Environment)                {}
+func (nopObserver) Moved(mnk.Environment, int, mnk.Action) {}
+func (nopObserver) Rejected(int, error)                    {}
+func (nopObserver) Ended(int, bool)                        {}
+
+// Observers returns an observer following a round for each of obs, in order
+func Observers(obs ...Observer) Observer {
+	return observers(obs)
+}
+
+type observers []Observer
+
+func (o observers) Started(env mnk.Environment) {
+	for _, obs := range o {
+		obs.Started(env)
+	}
+}
+
+func (o observers) Moved(env mnk.Environment, player int, action mnk.Action) {
+	for _, obs := range o {
+		obs.Moved(env, player, action)
+	}
+}
+
+func (o observers) Rejected(player int, err error) {
+	for _, obs := range o {
+		obs.Rejected(player, err)
+	}
+}
+
+func (o observers) Ended(winner int, resigned bool) {
+	for _, obs := range o {
+		obs.Ended(winner, resigned)
+	}
+}
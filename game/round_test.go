@@ -0,0 +1,85 @@
+package game
+
+import (
+	"errors"
+	"testing"
+
+	"mnkagent/mnk"
+)
+
+// scriptedAgent plays the given moves in order, then fails with err if set
+type scriptedAgent struct {
+	moves []mnk.MNKAction
+	err   error
+	final mnk.State
+}
+
+func (a *scriptedAgent) FetchMessage() string { return "" }
+
+func (a *scriptedAgent) FetchMove(mnk.State, []mnk.Action) (mnk.Action, error) {
+	if len(a.moves) == 0 && a.err != nil {
+		return nil, a.err
+	} else if len(a.moves) == 0 {
+		return nil, mnk.ErrResign
+	}
+	move := a.moves[0]
+	a.moves = a.moves[1:]
+	return move, nil
+}
+
+func (a *scriptedAgent) GameOver(s mnk.State) { a.final = s }
+
+func (a *scriptedAgent) GetSign() string { return "" }
+
+// countingObserver counts the events of a round
+type countingObserver struct {
+	moves, rejected, winner int
+	resigned                bool
+}
+
+func (o *countingObserver) Started(mnk.Environment)                {}
+func (o *countingObserver) Moved(mnk.Environment, int, mnk.Action) { o.moves++ }
+func (o *countingObserver) Rejected(int, error)                    { o.rejected++ }
+func (o *countingObserver) Ended(winner int, resigned bool)        { o.winner, o.resigned = winner, resigned }
+
+func TestRound(t *testing.T) {
+	env, _ := mnk.NewMNKBoard(3, 3, 3)
+	x := &scriptedAgent{moves: []mnk.MNKAction{{Y: 0, X: 0}, {Y: 0, X: 1}, {Y: 0, X: 2}}}
+	o := &scriptedAgent{moves: []mnk.MNKAction{{Y: 0, X: 0}, {Y: 1, X: 0}, {Y: 1, X: 1}}}
+	obs := new(countingObserver)
+
+	winner, err := Round(env, [3]mnk.Agent{nil, x, o}, 1, obs)
+	if err != nil || winner != 1 {
+		t.Fatalf("Round(): Expected X to win, actual %d (%v)", winner, err)
+	}
+	if obs.moves != 5 || obs.rejected != 1 || obs.winner != 1 || obs.resigned {
+		t.Errorf("Round(): Expected 5 moves and 1 rejected move, actual %+v", *obs)
+	}
+	if x.final == nil || o.final == nil {
+		t.Error("Round(): Expected both agents to see the final state")
+	}
+
+	// Running out of moves resigns
+	winner, _ = Round(env, [3]mnk.Agent{nil, x, o}, 2, obs)
+	if winner != 1 || !obs.resigned {
+		t.Errorf("Round(): Expected O to resign, actual winner %d", winner)
+	}
+}
+
+func TestRoundFails(t *testing.T) {
+	env, _ := mnk.NewMNKBoard(3, 3, 3)
+	lost := errors.New("connection lost")
+	x := &scriptedAgent{moves: []mnk.MNKAction{{Y: 0, X: 0}, {Y: 0, X: 1}}, err: lost}
+	o := &scriptedAgent{moves: []mnk.MNKAction{{Y: 1, X: 0}, {Y: 1, X: 1}}}
+	obs := new(countingObserver)
+
+	if _, err := Round(env, [3]mnk.Agent{nil, x, o}, 1, obs); err != lost {
+		t.Fatalf("Round(): Expected the error of X, actual %v", err)
+	}
+	if obs.moves != 4 {
+		t.Errorf("Round(): Expected 4 moves before the error, actual %d", obs.moves)
+	}
+	if x.final == nil || o.final == nil {
+		t.Error("Round(): Expected both agents to end the failed game")
+	}
+}
@@ -1,4 +1,11 @@
-package main
+// Package mnk implements the m,n,k-game environment and the interfaces its
+// agents play through
+package mnk
+
+import "errors"
+
+// ErrResign is returned by agents whose player gave up the game
+var ErrResign = errors.New("agent: resigned")
 
 // Environment interface
 type Environment interface {
@@ -1,4 +1,4 @@
-package main
+package mnk
 
 import "errors"
 
@@ -28,6 +28,17 @@ func (b *MNKBoard) GetState() State {
 	return b.board.Clone()
 }
 
+// SetState puts the board in the given state, which it keeps using rather
+// than copies
+func (b *MNKBoard) SetState(s MNKState) {
+	b.board = s
+}
+
+// Size returns the dimensions of the board and the marks in a row to win
+func (b *MNKBoard) Size() (m, n, k int) {
+	return b.m, b.n, b.k
+}
+
 func (b *MNKBoard) GetPotentialActions(agentID int) (a []Action) {
 	for i := range b.board {
 		for j := range b.board[i] {
@@ -257,14 +268,19 @@ func (b *MNKBoard) EvaluateAction(agentID int, action Action) int {
 }
 
 func (b *MNKBoard) Reset() {
-	b.board = make([][]int, n)
+	b.board = make([][]int, b.n)
 	for i := range b.board {
-		b.board[i] = make([]int, m)
+		b.board[i] = make([]int, b.m)
 	}
 }
 
 type MNKState [][]int
 
+// Opponent returns the id of the other player
+func Opponent(player int) int {
+	return 3 - player
+}
+
 func (s MNKState) Clone() (sp MNKState) {
 	sp = make([][]int, len(s))
 	for i := range s {
@@ -274,6 +290,32 @@ func (s MNKState) Clone() (sp MNKState) {
 	return
 }
 
+// EmptyCells lists the actions available on the state
+func (s MNKState) EmptyCells() (a []MNKAction) {
+	for i := range s {
+		for j := range s[i] {
+			if s[i][j] == 0 {
+				a = append(a, MNKAction{Y: i, X: j})
+			}
+		}
+	}
+	return
+}
+
+// Swapped returns a copy of the state with the marks of the two players
+// exchanged
+func (s MNKState) Swapped() (sp MNKState) {
+	sp = s.Clone()
+	for i := range sp {
+		for j := range sp[i] {
+			if sp[i][j] != 0 {
+				sp[i][j] = 3 - sp[i][j]
+			}
+		}
+	}
+	return
+}
+
 type MNKAction struct {
 	Y, X int
 }
@@ -0,0 +1,30 @@
+package mnk
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// seedSource hands out the seeds of the random generators of agents and
+// other stochastic components. Each component draws from its own generator,
+// so that a run is reproduced by its seed regardless of how the components
+// interleave.
+var (
+	seedSource   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	seedSourceMu sync.Mutex
+)
+
+// Seed makes the generators created from now on derive from seed
+func Seed(seed int64) {
+	seedSourceMu.Lock()
+	defer seedSourceMu.Unlock()
+	seedSource = rand.New(rand.NewSource(seed))
+}
+
+// NewRand returns a random generator with the next seed of the run
+func NewRand() *rand.Rand {
+	seedSourceMu.Lock()
+	defer seedSourceMu.Unlock()
+	return rand.New(rand.NewSource(seedSource.Int63()))
+}
@@ -0,0 +1,5 @@
+// Package rl implements the learning agents of m,n,k-games: tabular TD
+// learning with its algorithms, exploration policies and value store, and a
+// neural network agent, along with the persistence, export and maintenance
+// of their models
+package rl
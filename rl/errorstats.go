@@ -0,0 +1,44 @@
+package rl
+
+import (
+	"math"
+	"sync"
+)
+
+// ErrorStats accumulates absolute errors between reads, e.g. the TD errors of
+// learning agents for a metrics log
+type ErrorStats struct {
+	sum   float64
+	count int
+	mu    sync.Mutex
+}
+
+// Add records an error; a nil collector discards it
+func (s *ErrorStats) Add(err float64) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.sum += math.Abs(err)
+	s.count++
+	s.mu.Unlock()
+}
+
+// Take returns the mean absolute error and the number of errors since the
+// last call, none for a nil collector
+func (s *ErrorStats) Take() (mean float64, count int) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.count > 0 {
+		mean = s.sum / float64(s.count)
+	}
+	count = s.count
+	s.sum, s.count = 0, 0
+	return
+}
@@ -0,0 +1,204 @@
+package rl
+
+import (
+	"fmt"
+	"math"
+
+	"mnkagent/mnk"
+)
+
+// rlExploration is a policy for choosing between the agent's actions
+type rlExploration interface {
+	// choose returns the action to take in s, whether it is a greedy one, and
+	// a message describing the choice
+	choose(agent *RLAgent, s mnk.MNKState, possibleActions []mnk.Action) (action mnk.MNKAction, greedy bool, message string)
+
+	// policy returns the probabilities of choosing each of the given actions
+	// in s
+	policy(agent *RLAgent, s mnk.MNKState, actions []mnk.MNKAction) []float64
+}
+
+// Explorations lists the available exploration policies by name
+var Explorations = map[string]rlExploration{
+	"epsilon-greedy": epsilonGreedy{},
+	"softmax":        softmax{},
+	"ucb":            ucb{},
+	"optimistic":     optimistic{},
+}
+
+// epsilonGreedy picks a uniformly random action with probability epsilon
+type epsilonGreedy struct{}
+
+func (epsilonGreedy) choose(agent *RLAgent, s mnk.MNKState, possibleActions []mnk.Action) (mnk.MNKAction, bool, string) {
+	var e = agent.rng.Float64()
+	if e < agent.CurrentExplorationFactor() {
+		// Choose a random move
+		action := possibleActions[agent.rng.Intn(len(possibleActions))].GetParams().(mnk.MNKAction)
+		return action, false, fmt.Sprintf("Exploratory action (epsilon-greedy, %f)", e)
+	}
+
+	action, _ := agent.greedy(s, agent.actionValue)
+	return action, true, fmt.Sprintf("Greedy action (epsilon-greedy, %f)", e)
+}
+
+func (epsilonGreedy) policy(agent *RLAgent, s mnk.MNKState, actions []mnk.MNKAction) []float64 {
+	var epsilon = agent.CurrentExplorationFactor()
+	var p = greedyPolicy(agent, s, actions)
+	for i := range p {
+		p[i] = (1-epsilon)*p[i] + epsilon/float64(len(actions))
+	}
+	return p
+}
+
+// softmax picks actions with probabilities following a Boltzmann distribution
+// over their values, so that better actions are explored more often
+type softmax struct{}
+
+func (softmax) choose(agent *RLAgent, s mnk.MNKState, _ []mnk.Action) (mnk.MNKAction, bool, string) {
+	var t = agent.temperature()
+	best, qMax := agent.greedy(s, agent.actionValue)
+	if t <= 0 {
+		return best, true, "Greedy action (softmax, T=0)"
+	}
+
+	var actions = s.EmptyCells()
+	var p = softmax{}.policy(agent, s, actions)
+
+	var r = agent.rng.Float64()
+	var i int
+	for i = 0; i < len(actions)-1 && r >= p[i]; i++ {
+		r -= p[i]
+	}
+
+	if agent.actionValue(s, actions[i]) == qMax {
+		return actions[i], true, fmt.Sprintf("Greedy action (softmax, T=%.3f, p=%.3f)", t, p[i])
+	}
+	return actions[i], false, fmt.Sprintf("Exploratory action (softmax, T=%.3f, p=%.3f)", t, p[i])
+}
+
+func (softmax) policy(agent *RLAgent, s mnk.MNKState, actions []mnk.MNKAction) []float64 {
+	var t = agent.temperature()
+	if t <= 0 {
+		return greedyPolicy(agent, s, actions)
+	}
+
+	_, qMax := agent.greedy(s, agent.actionValue)
+	var p = make([]float64, len(actions))
+	var sum float64
+	for i, a := range actions {
+		// Shifted by the maximum to keep the exponentials finite
+		p[i] = math.Exp((agent.actionValue(s, a) - qMax) / t)
+		sum += p[i]
+	}
+	for i := range p {
+		p[i] /= sum
+	}
+	return p
+}
+
+// ucb picks the action with the highest upper confidence bound (UCB1), so that
+// rarely tried actions are explored
+type ucb struct{}
+
+func (ucb) choose(agent *RLAgent, s mnk.MNKState, _ []mnk.Action) (mnk.MNKAction, bool, string) {
+	// Valuing the actions first brings them into memory along with their
+	// visits
+	best, qMax := agent.greedy(s, agent.actionValue)
+
+	var actions = s.EmptyCells()
+	var bonuses, untried = ucb{}.bonuses(agent, s, actions)
+
+	// Untried actions have an unbounded confidence interval
+	if len(untried) > 0 {
+		action := actions[untried[agent.rng.Intn(len(untried))]]
+		return action, action == best, "Exploratory action (ucb, untried)"
+	}
+
+	i := ucb{}.best(agent, s, actions, bonuses)
+	if agent.actionValue(s, actions[i]) == qMax {
+		return actions[i], true, fmt.Sprintf("Greedy action (ucb, bonus %.3f)", bonuses[i])
+	}
+	return actions[i], false, fmt.Sprintf("Exploratory action (ucb, bonus %.3f)", bonuses[i])
+}
+
+func (ucb) policy(agent *RLAgent, s mnk.MNKState, actions []mnk.MNKAction) []float64 {
+	// Valuing the actions first brings them into memory along with their
+	// visits
+	agent.greedy(s, agent.actionValue)
+
+	var p = make([]float64, len(actions))
+	var bonuses, untried = ucb{}.bonuses(agent, s, actions)
+	if len(untried) > 0 {
+		for _, i := range untried {
+			p[i] = 1 / float64(len(untried))
+		}
+		return p
+	}
+
+	if len(actions) > 0 {
+		p[ucb{}.best(agent, s, actions, bonuses)] = 1
+	}
+	return p
+}
+
+// bonuses returns the UCB1 bonus of each action, along with the indexes of
+// the actions never tried, whose bonus is unbounded
+func (ucb) bonuses(agent *RLAgent, s mnk.MNKState, actions []mnk.MNKAction) (bonuses []float64, untried []int) {
+	var visits = make([]uint, len(actions))
+	var total uint
+	for i, a := range actions {
+		visits[i] = agent.knowledge.visits(agent.Key(s, a))
+		total += visits[i]
+		if visits[i] == 0 {
+			untried = append(untried, i)
+		}
+	}
+
+	bonuses = make([]float64, len(actions))
+	for i := range actions {
+		if visits[i] > 0 {
+			bonuses[i] = agent.UCBFactor * math.Sqrt(math.Log(float64(total))/float64(visits[i]))
+		}
+	}
+	return
+}
+
+// best returns the index of the action with the highest upper bound
+func (ucb) best(agent *RLAgent, s mnk.MNKState, actions []mnk.MNKAction, bonuses []float64) (best int) {
+	var uMax float64
+	for i, a := range actions {
+		if u := agent.actionValue(s, a) + bonuses[i]; u > uMax || i == 0 {
+			best, uMax = i, u
+		}
+	}
+	return
+}
+
+// optimistic always acts greedily; exploration comes from unseen pairs
+// starting at an optimistic value, which wears off as they are tried
+type optimistic struct{}
+
+func (optimistic) choose(agent *RLAgent, s mnk.MNKState, _ []mnk.Action) (mnk.MNKAction, bool, string) {
+	action, qMax := agent.greedy(s, agent.actionValue)
+	return action, true, fmt.Sprintf("Greedy action (optimistic, value %.3f)", qMax)
+}
+
+func (optimistic) policy(agent *RLAgent, s mnk.MNKState, actions []mnk.MNKAction) []float64 {
+	return greedyPolicy(agent, s, actions)
+}
+
+// greedyPolicy always chooses the greedy action among the given ones
+func greedyPolicy(agent *RLAgent, s mnk.MNKState, actions []mnk.MNKAction) []float64 {
+	var p = make([]float64, len(actions))
+	var best int
+	var qMax float64
+	for i, a := range actions {
+		if v := agent.actionValue(s, a); v > qMax || i == 0 {
+			best, qMax = i, v
+		}
+	}
+	if len(actions) > 0 {
+		p[best] = 1
+	}
+	return p
+}
@@ -0,0 +1,279 @@
+package rl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ModelExport is the portable form of an RL model. States are table keys, in
+// the notation of MarshallAfterstate for afterstate models and of
+// MarshallState otherwise.
+type ModelExport struct {
+	M           int          `json:"m"`
+	N           int          `json:"n"`
+	K           int          `json:"k"`
+	Iterations  uint         `json:"iterations"`
+	Algorithm   string       `json:"algorithm"`
+	Afterstates bool         `json:"afterstates"`
+	Entries     []ModelEntry `json:"entries"`
+}
+
+// ModelEntry is a single value of the table
+type ModelEntry struct {
+	State       string  `json:"state"`
+	Value       float64 `json:"value"`
+	Visits      uint    `json:"visits"`
+	Updates     uint    `json:"updates"`
+	LastUpdated uint    `json:"last_updated"`
+	Table       string  `json:"table,omitempty"` // "b" for the second table of Double Q-learning
+}
+
+// ModelColumns are the CSV columns of an entry; files without a header
+// row list them in this order
+var ModelColumns = []string{"state", "value", "visits", "updates", "last_updated", "table"}
+
+// ModelFormat returns the given format, or the one implied by the extension
+// of path
+func ModelFormat(format, path string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	if format != "json" && format != "csv" {
+		return "", fmt.Errorf("model: unknown format %q (json|csv)", format)
+	}
+	return format, nil
+}
+
+// NewModelExport returns the portable form of the knowledge, sorted by state
+func NewModelExport(kw *RLAgentKnowledge, m, n, k int) *ModelExport {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+
+	e := &ModelExport{
+		M:           m,
+		N:           n,
+		K:           k,
+		Iterations:  kw.Iterations,
+		Algorithm:   kw.AlgorithmName(),
+		Afterstates: kw.Afterstates,
+	}
+
+	for state, v := range kw.Values {
+		stats := kw.Stats[state]
+		e.Entries = append(e.Entries, ModelEntry{State: state, Value: v,
+			Visits: stats.Visits, Updates: stats.Updates, LastUpdated: stats.LastUpdated})
+	}
+	for state, v := range kw.ValuesB {
+		e.Entries = append(e.Entries, ModelEntry{State: state, Value: v, Table: "b"})
+	}
+
+	sort.Slice(e.Entries, func(i, j int) bool {
+		if e.Entries[i].Table != e.Entries[j].Table {
+			return e.Entries[i].Table < e.Entries[j].Table
+		}
+		return e.Entries[i].State < e.Entries[j].State
+	})
+	return e
+}
+
+// Write encodes the export in the given format
+func (e *ModelExport) Write(w io.Writer, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(e)
+	}
+
+	// Metadata goes in comment lines ahead of the records
+	fmt.Fprintf(w, "# m=%d\n# n=%d\n# k=%d\n", e.M, e.N, e.K)
+	fmt.Fprintf(w, "# iterations=%d\n# algorithm=%s\n# afterstates=%t\n",
+		e.Iterations, e.Algorithm, e.Afterstates)
+
+	cw := csv.NewWriter(w)
+	cw.Write(ModelColumns)
+	for _, entry := range e.Entries {
+		cw.Write([]string{
+			entry.State,
+			strconv.FormatFloat(entry.Value, 'g', -1, 64),
+			strconv.FormatUint(uint64(entry.Visits), 10),
+			strconv.FormatUint(uint64(entry.Updates), 10),
+			strconv.FormatUint(uint64(entry.LastUpdated), 10),
+			entry.Table,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadModelExport decodes an export in the given format
+func ReadModelExport(r io.Reader, format string) (*ModelExport, error) {
+	e := new(ModelExport)
+	if format == "json" {
+		if err := json.NewDecoder(r).Decode(e); err != nil {
+			return nil, fmt.Errorf("model: %v", err)
+		}
+		return e, nil
+	}
+
+	// Split off the metadata comments
+	var records bytes.Buffer
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#") {
+			records.WriteString(line + "\n")
+			continue
+		}
+
+		key, value, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "#")), "=")
+		var err error
+		switch key {
+		case "m":
+			e.M, err = strconv.Atoi(value)
+		case "n":
+			e.N, err = strconv.Atoi(value)
+		case "k":
+			e.K, err = strconv.Atoi(value)
+		case "iterations":
+			var i uint64
+			i, err = strconv.ParseUint(value, 10, 0)
+			e.Iterations = uint(i)
+		case "algorithm":
+			e.Algorithm = value
+		case "afterstates":
+			e.Afterstates, err = strconv.ParseBool(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("model: invalid %s %q", key, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	cr := csv.NewReader(&records)
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("model: %v", err)
+	}
+
+	var columns = ModelColumns
+	if len(rows) > 0 && slices.Contains(rows[0], "state") && slices.Contains(rows[0], "value") {
+		columns, rows = rows[0], rows[1:]
+	}
+
+	for i, row := range rows {
+		var entry ModelEntry
+		var seen = make(map[string]bool)
+		for c, field := range row {
+			if c >= len(columns) || field == "" {
+				continue
+			}
+			seen[columns[c]] = true
+
+			var err error
+			var count uint64
+			switch columns[c] {
+			case "state":
+				entry.State = field
+			case "value":
+				entry.Value, err = strconv.ParseFloat(field, 64)
+			case "visits", "updates", "last_updated":
+				count, err = strconv.ParseUint(field, 10, 0)
+			case "table":
+				entry.Table = field
+			}
+			if err != nil {
+				return nil, fmt.Errorf("model: invalid %s %q on record %d", columns[c], field, i+1)
+			}
+
+			switch columns[c] {
+			case "visits":
+				entry.Visits = uint(count)
+			case "updates":
+				entry.Updates = uint(count)
+			case "last_updated":
+				entry.LastUpdated = uint(count)
+			}
+		}
+
+		if !seen["state"] || !seen["value"] {
+			return nil, fmt.Errorf("model: record %d needs at least a state and a value", i+1)
+		}
+		e.Entries = append(e.Entries, entry)
+	}
+	return e, nil
+}
+
+// Apply loads the export into the knowledge, replacing its values unless
+// merge is set. An export without an algorithm keeps the knowledge's own.
+func (e *ModelExport) Apply(kw *RLAgentKnowledge, m, n int, merge bool) error {
+	if (e.M != 0 && e.M != m) || (e.N != 0 && e.N != n) {
+		return fmt.Errorf("model: export is of a %d,%d board, not %d,%d", e.M, e.N, m, n)
+	}
+	if e.Algorithm != "" {
+		if _, ok := Algorithms[e.Algorithm]; !ok {
+			return fmt.Errorf("model: unknown RL algorithm %q", e.Algorithm)
+		}
+	}
+
+	for _, entry := range e.Entries {
+		if len(entry.State) != m*n {
+			return fmt.Errorf("model: state %q does not have %d cells", entry.State, m*n)
+		}
+		if entry.Table != "" && entry.Table != "b" {
+			return fmt.Errorf("model: unknown table %q of %s", entry.Table, entry.State)
+		}
+	}
+
+	kw.mu.Lock()
+	if merge && len(kw.Values) > 0 && kw.Afterstates != e.Afterstates {
+		kw.mu.Unlock()
+		return fmt.Errorf("model: cannot merge afterstate and state-action values")
+	}
+	if !merge {
+		kw.Values = nil
+		kw.ValuesB = nil
+		kw.Stats = nil
+		kw.Iterations = e.Iterations
+	}
+	if e.Algorithm != "" {
+		kw.Algorithm = e.Algorithm
+	}
+	kw.Afterstates = e.Afterstates
+	kw.mu.Unlock()
+
+	kw.init(m, n)
+
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+	for _, entry := range e.Entries {
+		if entry.Table == "b" {
+			if kw.ValuesB == nil {
+				kw.ValuesB = make(map[string]float64)
+			}
+			kw.ValuesB[entry.State] = entry.Value
+			continue
+		}
+
+		kw.Values[entry.State] = entry.Value
+		if entry.Visits > 0 || entry.Updates > 0 {
+			kw.Stats[entry.State] = RLStats{
+				Visits:      entry.Visits,
+				Updates:     entry.Updates,
+				LastUpdated: entry.LastUpdated,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package rl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestModelExportRoundTrip(t *testing.T) {
+	var kw = RLAgentKnowledge{
+		Values:     map[string]float64{"xo.......": 0.5, "x.o......": -0.25},
+		ValuesB:    map[string]float64{"xo.......": 0.75},
+		Stats:      map[string]RLStats{"xo.......": {Visits: 3, Updates: 5, LastUpdated: 40}},
+		Iterations: 42,
+		Algorithm:  "double-q",
+	}
+	kw.Afterstates = true
+
+	for _, format := range []string{"json", "csv"} {
+		var buf bytes.Buffer
+		if err := NewModelExport(&kw, 3, 3, 3).Write(&buf, format); err != nil {
+			t.Fatalf("Write(%s): Unexpected error %v", format, err)
+		}
+
+		e, err := ReadModelExport(&buf, format)
+		if err != nil {
+			t.Fatalf("ReadModelExport(%s): Unexpected error %v", format, err)
+		}
+
+		var imported RLAgentKnowledge
+		if err = e.Apply(&imported, 3, 3, false); err != nil {
+			t.Fatalf("Apply(%s): Unexpected error %v", format, err)
+		}
+
+		if imported.Iterations != 42 || imported.Algorithm != "double-q" || !imported.Afterstates {
+			t.Errorf("%s: Expected the metadata to survive, actual %d %q %t", format,
+				imported.Iterations, imported.Algorithm, imported.Afterstates)
+		}
+		if imported.Values["x.o......"] != -0.25 || imported.ValuesB["xo......."] != 0.75 ||
+			imported.Stats["xo......."] != (RLStats{3, 5, 40}) {
+			t.Errorf("%s: Expected the entries to survive, actual %v %v %v", format,
+				imported.Values, imported.ValuesB, imported.Stats)
+		}
+	}
+}
+
+func TestModelImportExternalCSV(t *testing.T) {
+	// A bare table, as computed by an external tool
+	e, err := ReadModelExport(strings.NewReader("XXX------,1\nXO-------,0\n"), "csv")
+	if err != nil {
+		t.Fatalf("ReadModelExport(): Unexpected error %v", err)
+	}
+	if len(e.Entries) != 2 {
+		t.Fatalf("ReadModelExport(): Expected 2 entries, actual %d", len(e.Entries))
+	}
+
+	// Columns are picked by the header
+	e, err = ReadModelExport(strings.NewReader("value,state\n1,XXX------\n0,XO-------\n"), "csv")
+	if err != nil {
+		t.Fatalf("ReadModelExport(): Unexpected error %v", err)
+	}
+
+	var kw = RLAgentKnowledge{Values: map[string]float64{"OO-------": -1}}
+	if err = e.Apply(&kw, 3, 3, true); err != nil {
+		t.Fatalf("Apply(): Unexpected error %v", err)
+	}
+	if len(kw.Values) != 3 || kw.Values["XXX------"] != 1 {
+		t.Errorf("Apply(): Expected the entries to be merged, actual %v", kw.Values)
+	}
+
+	if err = e.Apply(&kw, 4, 4, true); err == nil {
+		t.Error("Apply(): Expected an error for the wrong board size")
+	}
+}
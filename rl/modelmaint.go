@@ -0,0 +1,160 @@
+package rl
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ModelChange is the difference of a single state between two models
+type ModelChange struct {
+	State  string
+	Before float64
+	After  float64
+}
+
+// Delta returns the change of the value, counting a missing state as zero
+func (c ModelChange) Delta() float64 {
+	return c.After - c.Before
+}
+
+// ReadModel loads the RL model at path
+func ReadModel(path string) (*RLAgentKnowledge, error) {
+	kw := new(RLAgentKnowledge)
+	if !kw.LoadFromFile(path) {
+		return nil, fmt.Errorf("model: could not load %s", path)
+	}
+	if err := kw.LoadAll(); err != nil {
+		return nil, err
+	}
+	return kw, nil
+}
+
+// MergeModels combines models trained separately into one. Values of a state
+// are averaged weighted by its visits in each model, or evenly where it was
+// never visited; visits, updates and iterations add up.
+func MergeModels(models []*RLAgentKnowledge) (*RLAgentKnowledge, error) {
+	if len(models) == 0 {
+		return nil, fmt.Errorf("model: nothing to merge")
+	}
+
+	merged := &RLAgentKnowledge{
+		Values:          make(map[string]float64),
+		Stats:           make(map[string]RLStats),
+		Algorithm:       models[0].AlgorithmName(),
+		Afterstates:     models[0].Afterstates,
+		AlphaSchedule:   models[0].AlphaSchedule,
+		EpsilonSchedule: models[0].EpsilonSchedule,
+		Exploration:     models[0].Exploration,
+	}
+	for _, kw := range models[1:] {
+		if kw.AlgorithmName() != merged.Algorithm || kw.Afterstates != merged.Afterstates {
+			return nil, fmt.Errorf("model: cannot merge %s models with %s models",
+				DescribeModel(kw), DescribeModel(merged))
+		}
+	}
+
+	// States visited in any model are weighted by visits, others evenly
+	var visited = make(map[string]bool)
+	for _, kw := range models {
+		for state := range kw.Values {
+			if kw.Stats[state].Visits > 0 {
+				visited[state] = true
+			}
+		}
+	}
+	weight := func(kw *RLAgentKnowledge, state string) float64 {
+		if visited[state] {
+			return float64(kw.Stats[state].Visits)
+		}
+		return 1
+	}
+
+	var total = make(map[string]float64)
+	for _, kw := range models {
+		merged.Iterations += kw.Iterations
+		for state, v := range kw.Values {
+			w := weight(kw, state)
+			merged.Values[state] += w * v
+			total[state] += w
+			if stats, ok := kw.Stats[state]; ok {
+				sum := merged.Stats[state]
+				sum.Visits += stats.Visits
+				sum.Updates += stats.Updates
+				if stats.LastUpdated > sum.LastUpdated {
+					sum.LastUpdated = stats.LastUpdated
+				}
+				merged.Stats[state] = sum
+			}
+		}
+	}
+	for state := range merged.Values {
+		merged.Values[state] /= total[state]
+	}
+
+	if merged.Algorithm == "double-q" {
+		var totalB = make(map[string]float64)
+		merged.ValuesB = make(map[string]float64)
+		for _, kw := range models {
+			for state, v := range kw.ValuesB {
+				w := weight(kw, state)
+				merged.ValuesB[state] += w * v
+				totalB[state] += w
+			}
+		}
+		for state := range merged.ValuesB {
+			merged.ValuesB[state] /= totalB[state]
+		}
+	}
+
+	return merged, nil
+}
+
+// DescribeModel names the kind of values of a model
+func DescribeModel(kw *RLAgentKnowledge) string {
+	if kw.Afterstates {
+		return kw.AlgorithmName() + " afterstate"
+	}
+	return kw.AlgorithmName()
+}
+
+// PruneModel drops states visited fewer than minVisits times or, for a
+// positive epsilon, valued within epsilon of zero, and returns the number of
+// states dropped
+func PruneModel(kw *RLAgentKnowledge, minVisits uint, epsilon float64) (pruned int) {
+	for state, v := range kw.Values {
+		if kw.Stats[state].Visits >= minVisits && (epsilon <= 0 || math.Abs(v) > epsilon) {
+			continue
+		}
+
+		delete(kw.Values, state)
+		delete(kw.ValuesB, state)
+		delete(kw.Stats, state)
+		pruned++
+	}
+	return
+}
+
+// DiffModels returns the states whose values differ between two models, the
+// largest changes first
+func DiffModels(before, after *RLAgentKnowledge) (changes []ModelChange) {
+	for state, v := range after.Values {
+		if b, ok := before.Values[state]; !ok || b != v {
+			changes = append(changes, ModelChange{State: state, Before: b, After: v})
+		}
+	}
+	for state, b := range before.Values {
+		if _, ok := after.Values[state]; !ok {
+			changes = append(changes, ModelChange{State: state, Before: b})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		di, dj := math.Abs(changes[i].Delta()), math.Abs(changes[j].Delta())
+		if di == dj {
+			return changes[i].State < changes[j].State
+		}
+		return di > dj
+	})
+	return
+}
@@ -0,0 +1,64 @@
+package rl
+
+import "testing"
+
+func TestMergeModels(t *testing.T) {
+	a := &RLAgentKnowledge{
+		Values:     map[string]float64{"s": 1, "u": 1},
+		Stats:      map[string]RLStats{"s": {Visits: 3, Updates: 3}},
+		Iterations: 10,
+	}
+	b := &RLAgentKnowledge{
+		Values:     map[string]float64{"s": -1, "u": 0, "v": 2},
+		Stats:      map[string]RLStats{"s": {Visits: 1, Updates: 2}},
+		Iterations: 5,
+	}
+
+	merged, err := MergeModels([]*RLAgentKnowledge{a, b})
+	if err != nil {
+		t.Fatalf("MergeModels(): Unexpected error %v", err)
+	}
+
+	// Visit-weighted, evenly weighted and single-model states
+	for state, want := range map[string]float64{"s": 0.5, "u": 0.5, "v": 2} {
+		if v := merged.Values[state]; v != want {
+			t.Errorf("MergeModels(): Expected %s = %g, actual %g", state, want, v)
+		}
+	}
+	if merged.Stats["s"].Visits != 4 || merged.Stats["s"].Updates != 5 || merged.Iterations != 15 {
+		t.Errorf("MergeModels(): Expected 4 visits, 5 updates and 15 iterations, actual %+v and %d",
+			merged.Stats["s"], merged.Iterations)
+	}
+
+	b.Afterstates = true
+	if _, err = MergeModels([]*RLAgentKnowledge{a, b}); err == nil {
+		t.Error("MergeModels(): Expected an error for afterstate and state-action models")
+	}
+}
+
+func TestPruneAndDiffModels(t *testing.T) {
+	kw := &RLAgentKnowledge{
+		Values: map[string]float64{"rare": 1, "zero": 0.001, "kept": 0.5},
+		Stats:  map[string]RLStats{"rare": {Visits: 1}, "zero": {Visits: 10}, "kept": {Visits: 10}},
+	}
+	before := &RLAgentKnowledge{Values: map[string]float64{"rare": 1, "zero": 0.001, "kept": 0}}
+
+	if pruned := PruneModel(kw, 2, 0.01); pruned != 2 {
+		t.Errorf("PruneModel(): Expected 2 states dropped, actual %d", pruned)
+	}
+	if _, ok := kw.Values["kept"]; !ok || len(kw.Values) != 1 {
+		t.Errorf("PruneModel(): Expected only kept to remain, actual %v", kw.Values)
+	}
+
+	// States valued exactly zero are only dropped for a positive epsilon
+	kw.Values["learned"], kw.Stats["learned"] = 0, RLStats{Visits: 10}
+	if pruned := PruneModel(kw, 2, 0); pruned != 0 {
+		t.Errorf("PruneModel(): Expected no state dropped without epsilon, actual %d", pruned)
+	}
+	delete(kw.Values, "learned")
+
+	changes := DiffModels(before, kw)
+	if len(changes) != 3 || changes[0].State != "rare" || changes[0].Delta() != -1 {
+		t.Errorf("DiffModels(): Expected rare to change most, actual %v", changes)
+	}
+}
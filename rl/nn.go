@@ -0,0 +1,224 @@
+package rl
+
+import (
+	"errors"
+	"math"
+	"slices"
+
+	"mnkagent/mnk"
+)
+
+// Adam optimizer parameters
+const (
+	adamBeta1   = 0.9
+	adamBeta2   = 0.999
+	adamEpsilon = 1e-8
+)
+
+// MLP is a fully connected network with tanh activations, predicting a single
+// value in [-1, 1]
+type MLP struct {
+	Sizes   []int       // Units per layer, input first and output last
+	Weights [][]float64 // Per layer, Sizes[l+1] rows of Sizes[l] columns
+	Biases  [][]float64
+
+	// Optimizer settings and state
+	Optimizer    string // sgd|adam
+	LearningRate float64
+	Step         int
+	MW, VW       [][]float64 // Adam moments of the weights
+	MB, VB       [][]float64 // Adam moments of the biases
+
+	// Accumulated gradients of the current batch
+	gw, gb [][]float64
+	batch  int
+}
+
+// NewMLP creates a network with the given layer sizes and Xavier initialized
+// weights
+func NewMLP(sizes []int, optimizer string, learningRate float64) (*MLP, error) {
+	if len(sizes) < 2 || sizes[len(sizes)-1] != 1 {
+		return nil, errors.New("nn: the network needs an input layer and a single output")
+	}
+	if optimizer != "sgd" && optimizer != "adam" {
+		return nil, errors.New("nn: unknown optimizer " + optimizer)
+	}
+	for _, s := range sizes {
+		if s < 1 {
+			return nil, errors.New("nn: layers must have at least one unit")
+		}
+	}
+
+	net := &MLP{
+		Sizes:        sizes,
+		Optimizer:    optimizer,
+		LearningRate: learningRate,
+	}
+
+	rng := mnk.NewRand()
+	for l := 0; l < len(sizes)-1; l++ {
+		limit := math.Sqrt(6 / float64(sizes[l]+sizes[l+1]))
+		w := make([]float64, sizes[l]*sizes[l+1])
+		for i := range w {
+			w[i] = (rng.Float64()*2 - 1) * limit
+		}
+		net.Weights = append(net.Weights, w)
+		net.Biases = append(net.Biases, make([]float64, sizes[l+1]))
+	}
+
+	net.MW, net.VW = net.zeros(), net.zeros()
+	net.MB, net.VB = net.zerosB(), net.zerosB()
+	return net, nil
+}
+
+// Clone returns a copy of the network's parameters; the copy starts a fresh
+// optimizer state
+func (net *MLP) Clone() *MLP {
+	c := &MLP{
+		Sizes:        slices.Clone(net.Sizes),
+		Optimizer:    net.Optimizer,
+		LearningRate: net.LearningRate,
+	}
+	for l := range net.Weights {
+		c.Weights = append(c.Weights, slices.Clone(net.Weights[l]))
+		c.Biases = append(c.Biases, slices.Clone(net.Biases[l]))
+	}
+	c.MW, c.VW = c.zeros(), c.zeros()
+	c.MB, c.VB = c.zerosB(), c.zerosB()
+	return c
+}
+
+// Predict returns the network's output for input x
+func (net *MLP) Predict(x []float64) float64 {
+	acts := net.forward(x)
+	return acts[len(acts)-1][0]
+}
+
+// Train performs a single optimization step towards the target for input x
+// and returns the prediction error before the step
+func (net *MLP) Train(x []float64, target float64) float64 {
+	err := net.Accumulate(x, target)
+	net.Apply()
+	return err
+}
+
+// Accumulate adds the gradient of the squared error for input x to the
+// current batch and returns the prediction error
+func (net *MLP) Accumulate(x []float64, target float64) float64 {
+	if net.gw == nil {
+		net.gw, net.gb = net.zeros(), net.zerosB()
+	}
+
+	acts := net.forward(x)
+	out := acts[len(acts)-1][0]
+
+	// Error signal at the output, through the tanh derivative
+	delta := []float64{(out - target) * (1 - out*out)}
+
+	for l := len(net.Weights) - 1; l >= 0; l-- {
+		in := acts[l]
+		cols := net.Sizes[l]
+
+		for i, d := range delta {
+			net.gb[l][i] += d
+			row := net.gw[l][i*cols : (i+1)*cols]
+			for j, a := range in {
+				row[j] += d * a
+			}
+		}
+
+		if l == 0 {
+			break
+		}
+
+		// Propagate to the previous hidden layer
+		prev := make([]float64, cols)
+		for i, d := range delta {
+			row := net.Weights[l][i*cols : (i+1)*cols]
+			for j, w := range row {
+				prev[j] += d * w
+			}
+		}
+		for j := range prev {
+			prev[j] *= 1 - in[j]*in[j]
+		}
+		delta = prev
+	}
+
+	net.batch++
+	return out - target
+}
+
+// Apply updates the parameters with the mean gradient of the current batch
+func (net *MLP) Apply() {
+	if net.batch == 0 {
+		return
+	}
+
+	scale := 1 / float64(net.batch)
+	net.Step++
+
+	for l := range net.Weights {
+		net.update(net.Weights[l], net.gw[l], net.MW[l], net.VW[l], scale)
+		net.update(net.Biases[l], net.gb[l], net.MB[l], net.VB[l], scale)
+
+		clear(net.gw[l])
+		clear(net.gb[l])
+	}
+	net.batch = 0
+}
+
+// update applies the optimizer to a single parameter vector
+func (net *MLP) update(p, g, m, v []float64, scale float64) {
+	if net.Optimizer == "sgd" {
+		for i := range p {
+			p[i] -= net.LearningRate * g[i] * scale
+		}
+		return
+	}
+
+	c1 := 1 - math.Pow(adamBeta1, float64(net.Step))
+	c2 := 1 - math.Pow(adamBeta2, float64(net.Step))
+	for i := range p {
+		gi := g[i] * scale
+		m[i] = adamBeta1*m[i] + (1-adamBeta1)*gi
+		v[i] = adamBeta2*v[i] + (1-adamBeta2)*gi*gi
+		p[i] -= net.LearningRate * (m[i] / c1) / (math.Sqrt(v[i]/c2) + adamEpsilon)
+	}
+}
+
+// forward returns the activations of every layer, the input included
+func (net *MLP) forward(x []float64) [][]float64 {
+	acts := make([][]float64, len(net.Sizes))
+	acts[0] = x
+
+	for l, w := range net.Weights {
+		in := acts[l]
+		cols := net.Sizes[l]
+		out := make([]float64, net.Sizes[l+1])
+		for i := range out {
+			sum := net.Biases[l][i]
+			row := w[i*cols : (i+1)*cols]
+			for j, a := range in {
+				sum += row[j] * a
+			}
+			out[i] = math.Tanh(sum)
+		}
+		acts[l+1] = out
+	}
+	return acts
+}
+
+func (net *MLP) zeros() (z [][]float64) {
+	for _, w := range net.Weights {
+		z = append(z, make([]float64, len(w)))
+	}
+	return
+}
+
+func (net *MLP) zerosB() (z [][]float64) {
+	for _, b := range net.Biases {
+		z = append(z, make([]float64, len(b)))
+	}
+	return
+}
@@ -0,0 +1,65 @@
+package rl
+
+import (
+	"math"
+	"testing"
+)
+
+// TestMLPGradient compares backpropagated gradients to numeric ones
+func TestMLPGradient(t *testing.T) {
+	net, err := NewMLP([]int{4, 3, 2, 1}, "sgd", 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var x = []float64{1, 0, -0.5, 0.25}
+	var target = 0.3
+	var loss = func() float64 {
+		d := net.Predict(x) - target
+		return d * d / 2
+	}
+
+	net.Accumulate(x, target)
+
+	const h = 1e-6
+	for l := range net.Weights {
+		for i := range net.Weights[l] {
+			w := net.Weights[l][i]
+			net.Weights[l][i] = w + h
+			up := loss()
+			net.Weights[l][i] = w - h
+			down := loss()
+			net.Weights[l][i] = w
+
+			numeric := (up - down) / (2 * h)
+			if math.Abs(numeric-net.gw[l][i]) > 1e-6 {
+				t.Errorf("Gradient of weight %d/%d: Expected %g, actual %g",
+					l, i, numeric, net.gw[l][i])
+			}
+		}
+	}
+}
+
+func TestMLPTrain(t *testing.T) {
+	for _, optimizer := range []string{"sgd", "adam"} {
+		net, err := NewMLP([]int{2, 8, 1}, optimizer, 0.05)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Learn XOR on {-1, 1} inputs
+		var data = [][3]float64{{-1, -1, -0.5}, {-1, 1, 0.5}, {1, -1, 0.5}, {1, 1, -0.5}}
+		for epoch := 0; epoch < 2000; epoch++ {
+			for _, d := range data {
+				net.Accumulate(d[:2], d[2])
+			}
+			net.Apply()
+		}
+
+		for _, d := range data {
+			if p := net.Predict(d[:2]); math.Abs(p-d[2]) > 0.1 {
+				t.Errorf("%s: Predict(%v): Expected %g, actual %g", optimizer, d[:2], d[2], p)
+			}
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package rl
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+
+	"mnkagent/mnk"
+)
+
+// NNAgent learns afterstate values with a neural network, so that it can
+// generalize to positions it has never seen
+type NNAgent struct {
+	// Agent PlayerID
+	id int
+
+	// View settings
+	Sign string
+
+	// Game definition
+	m, n, k int
+
+	// RL parameters
+	Learning          bool
+	DiscountFactor    float64
+	ExplorationFactor float64 //epsilon
+	EpsilonSchedule   *Schedule
+
+	// Experience replay; the network is trained on sampled mini-batches
+	// instead of the latest transition when set
+	Replay      *ReplayBuffer
+	ReplayBatch int
+
+	// Collects the TD errors of the updates, if set
+	Errors *ErrorStats
+
+	// Network and the previous afterstate of the episode
+	model      *NNModel
+	prev       []float64
+	prevState  mnk.MNKState
+	prevAction mnk.MNKAction
+	prevReward float64
+	message    string
+
+	// Scratch environment used to evaluate rewards
+	env *mnk.MNKBoard
+
+	// Random generator of exploration
+	rng *rand.Rand
+}
+
+// NNModel is the network along with its training progress, stored next to
+// the tabular model
+type NNModel struct {
+	Net        *MLP
+	M, N       int
+	Iterations uint
+
+	// Exploration schedule over the model's iterations
+	EpsilonSchedule *Schedule
+
+	// Guards the network when agents play concurrent games
+	mu sync.Mutex
+}
+
+func NewNNAgent(id int, sign string, m, n, k int, model *NNModel, learn bool) (agent *NNAgent) {
+	agent = new(NNAgent)
+	agent.id = id
+	agent.Sign = sign
+
+	agent.m = m
+	agent.n = n
+	agent.k = k
+
+	// Default values
+	agent.Learning = learn
+	agent.DiscountFactor = 0.8
+	agent.ExplorationFactor = 0.25
+	agent.EpsilonSchedule = model.EpsilonSchedule
+
+	agent.model = model
+	agent.env, _ = mnk.NewMNKBoard(m, n, k) // Only ever given valid games
+	agent.rng = mnk.NewRand()
+	return
+}
+
+// NewNNModel creates an untrained network for an m by n board with the given
+// hidden layer sizes
+func NewNNModel(m, n int, hidden []int, optimizer string, learningRate float64) (*NNModel, error) {
+	sizes := append([]int{2 * m * n}, hidden...)
+	net, err := NewMLP(append(sizes, 1), optimizer, learningRate)
+	if err != nil {
+		return nil, err
+	}
+	return &NNModel{Net: net, M: m, N: n}, nil
+}
+
+// Freeze turns the agent into a greedy, non-learning player
+func (agent *NNAgent) Freeze() {
+	agent.Learning = false
+	agent.ExplorationFactor = 0
+	agent.EpsilonSchedule = nil
+}
+
+func (agent *NNAgent) FetchMessage() (message string) {
+	message = agent.message
+	agent.message = ""
+	return
+}
+
+func (agent *NNAgent) FetchMove(state mnk.State, possibleActions []mnk.Action) (mnk.Action, error) {
+	var s mnk.MNKState = state.(mnk.MNKState)
+	var action mnk.MNKAction
+	var input []float64
+	var reward, vMax float64
+
+	// Value every afterstate; winning moves are worth their reward
+	var first = true
+	var inputs = make(map[mnk.MNKAction][]float64)
+	var values = make(map[mnk.MNKAction]float64)
+	for _, pa := range possibleActions {
+		a := pa.GetParams().(mnk.MNKAction)
+		inputs[a] = agent.encode(s, a)
+		values[a] = agent.value(s, a)
+		if values[a] != 1 {
+			values[a] = agent.model.predict(inputs[a])
+		}
+
+		if values[a] > vMax || first {
+			vMax = values[a]
+			action = a
+			first = false
+		}
+	}
+
+	var e = agent.rng.Float64()
+	if e < agent.CurrentExplorationFactor() {
+		agent.message = fmt.Sprintf("Exploratory action (%f)", e)
+
+		// Choose a random move
+		action = possibleActions[agent.rng.Intn(len(possibleActions))].GetParams().(mnk.MNKAction)
+	} else {
+		agent.message = fmt.Sprintf("Greedy action (%f, value %.3f)", e, vMax)
+	}
+	input = inputs[action]
+	reward = agent.value(s, action)
+
+	if agent.Learning && agent.prev != nil {
+		if agent.Replay != nil {
+			agent.remember(s, false)
+		} else {
+			agent.Errors.Add(agent.model.train(agent.prev, agent.prevReward+agent.DiscountFactor*vMax))
+		}
+	}
+
+	agent.prev = input
+	agent.prevState = s
+	agent.prevAction = action
+	agent.prevReward = reward
+
+	return action, nil
+}
+
+func (agent *NNAgent) GameOver(state mnk.State) {
+	var s mnk.MNKState = state.(mnk.MNKState)
+
+	if agent.Learning && agent.prev != nil {
+		if agent.Replay != nil {
+			agent.remember(s, true)
+		} else {
+			// The outcome is the final afterstate's target
+			agent.Errors.Add(agent.model.train(agent.prev, agent.value(s, Terminal)))
+		}
+	}
+
+	// Restart for the next episode
+	agent.prev = nil
+	agent.prevState = nil
+	agent.prevReward = 0
+	agent.message = ""
+
+	if agent.Learning {
+		agent.model.mu.Lock()
+		agent.model.Iterations++
+		agent.model.mu.Unlock()
+	}
+}
+
+func (agent *NNAgent) GetSign() string {
+	return agent.Sign
+}
+
+// remember stores the transition from the previous afterstate to s and trains
+// the network on a mini-batch sampled from the replay buffer
+func (agent *NNAgent) remember(s mnk.MNKState, terminal bool) {
+	agent.Replay.Add(Transition{
+		Agent:    agent.id,
+		State:    agent.prevState,
+		Action:   agent.prevAction,
+		Reward:   agent.prevReward,
+		Next:     s,
+		Terminal: terminal,
+	})
+
+	indexes, weights := agent.Replay.Sample(agent.ReplayBatch)
+	for b, i := range indexes {
+		// Transitions of the other player are seen from this agent's side
+		t := agent.Replay.Get(i).as(agent.id)
+
+		var target float64
+		if t.Terminal {
+			target = agent.value(t.Next, Terminal)
+		} else {
+			target = t.Reward + agent.DiscountFactor*agent.best(t.Next)
+		}
+
+		err := agent.model.accumulate(agent.encode(t.State, t.Action), target, weights[b])
+		agent.Replay.Update(i, err)
+		agent.Errors.Add(err)
+	}
+	agent.model.apply()
+}
+
+// best returns the highest afterstate value among the moves of s
+func (agent *NNAgent) best(s mnk.MNKState) (vMax float64) {
+	var first = true
+	for _, a := range s.EmptyCells() {
+		v := agent.value(s, a)
+		if v != 1 {
+			v = agent.model.predict(agent.encode(s, a))
+		}
+
+		if v > vMax || first {
+			vMax = v
+			first = false
+		}
+	}
+	return
+}
+
+// CurrentExplorationFactor returns epsilon for the model's current iteration
+func (agent *NNAgent) CurrentExplorationFactor() float64 {
+	if agent.EpsilonSchedule == nil {
+		return agent.ExplorationFactor
+	}
+
+	agent.model.mu.Lock()
+	defer agent.model.mu.Unlock()
+	return agent.EpsilonSchedule.Value(agent.model.Iterations)
+}
+
+// encode returns the network input for the afterstate of action: one plane
+// of the agent's marks followed by one of the opponent's
+func (agent *NNAgent) encode(s mnk.MNKState, action mnk.MNKAction) []float64 {
+	var cells = agent.m * agent.n
+	var x = make([]float64, 2*cells)
+	for i := range s {
+		for j := range s[i] {
+			switch {
+			case i == action.Y && j == action.X, s[i][j] == agent.id:
+				x[i*agent.m+j] = 1
+			case s[i][j] != 0:
+				x[cells+i*agent.m+j] = 1
+			}
+		}
+	}
+	return x
+}
+
+// value returns the reward for the given state
+func (agent *NNAgent) value(state mnk.MNKState, action mnk.MNKAction) float64 {
+	agent.env.SetState(state)
+
+	if action != Terminal {
+		if agent.env.EvaluateAction(agent.id, action) == 1 { // Agent won
+			return 1
+		}
+		return 0
+	}
+
+	switch agent.env.Evaluate() {
+	case agent.id: // Agent won
+		return 1
+	case 0: // Game goes on
+		return 0
+	case -1: // Draw
+		return -0.5
+	default: // Agent lost
+		return -1
+	}
+}
+
+// Trained returns the number of iterations the network was trained for
+func (model *NNModel) Trained() uint {
+	model.mu.Lock()
+	defer model.mu.Unlock()
+	return model.Iterations
+}
+
+// Snapshot returns a copy of the network and its settings
+func (model *NNModel) Snapshot() *NNModel {
+	model.mu.Lock()
+	defer model.mu.Unlock()
+
+	return &NNModel{
+		Net:        model.Net.Clone(),
+		M:          model.M,
+		N:          model.N,
+		Iterations: model.Iterations,
+	}
+}
+
+func (model *NNModel) predict(x []float64) float64 {
+	model.mu.Lock()
+	defer model.mu.Unlock()
+	return model.Net.Predict(x)
+}
+
+func (model *NNModel) train(x []float64, target float64) float64 {
+	model.mu.Lock()
+	defer model.mu.Unlock()
+	return model.Net.Train(x, target)
+}
+
+// accumulate adds the gradient towards the target to the current batch, scaled
+// by an importance sampling weight, and returns the prediction error
+func (model *NNModel) accumulate(x []float64, target, weight float64) float64 {
+	model.mu.Lock()
+	defer model.mu.Unlock()
+
+	// Moving the target scales the squared error's gradient by the weight
+	out := model.Net.Predict(x)
+	model.Net.Accumulate(x, out+weight*(target-out))
+	return out - target
+}
+
+func (model *NNModel) apply() {
+	model.mu.Lock()
+	defer model.mu.Unlock()
+	model.Net.Apply()
+}
+
+// SaveToFile writes the model to given path
+func (model *NNModel) SaveToFile(path string) bool {
+	model.mu.Lock()
+	defer model.mu.Unlock()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		fmt.Println("[error] Could not open writable network file on disk!")
+		fmt.Println(err)
+		return false
+	}
+	defer file.Close()
+
+	enc := gob.NewEncoder(file)
+	err = enc.Encode(model)
+	if err != nil {
+		fmt.Println("[error] Encoding of network failed!")
+		fmt.Println(err)
+		return false
+	}
+
+	return true
+}
+
+// LoadFromFile reads the model from given path
+func (model *NNModel) LoadFromFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Println("[error] Could not open readable network file on disk!")
+		fmt.Println(err)
+		return false
+	}
+	defer file.Close()
+
+	dec := gob.NewDecoder(file)
+	err = dec.Decode(model)
+	if err != nil {
+		fmt.Println("[error] Decoding of network failed!")
+		fmt.Println(err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,245 @@
+package rl
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+
+	"mnkagent/mnk"
+)
+
+// Prioritized replay parameters
+const (
+	replayAlpha   = 0.6  // How much TD errors shape the sampling distribution
+	replayBeta    = 0.4  // Importance sampling correction
+	replayEpsilon = 1e-3 // Keeps transitions with no error sampleable
+)
+
+// Transition is a single step of an episode, seen by the acting agent
+type Transition struct {
+	Agent      int // Id of the acting agent
+	State      mnk.MNKState
+	Action     mnk.MNKAction
+	Reward     float64
+	Shaping    float64 // Shaping reward, on top of Reward
+	Next       mnk.MNKState
+	NextAction mnk.MNKAction // Action chosen in Next, for on-policy updates
+	Terminal   bool
+}
+
+// as returns the transition seen by agent id, swapping marks if it was made
+// by the other player
+func (t Transition) as(id int) Transition {
+	if t.Agent == id {
+		return t
+	}
+
+	t.Agent = id
+	t.State = t.State.Swapped()
+	t.Next = t.Next.Swapped()
+	return t
+}
+
+// ReplayBuffer is a bounded store of transitions that mini-batches are sampled
+// from, uniformly or in proportion to their TD errors
+type ReplayBuffer struct {
+	Capacity    int
+	Items       []Transition
+	Pos         int // Where the next transition is written once full
+	Prioritized bool
+	Priorities  []float64
+
+	// Sum tree over the priorities; leaves start at index Capacity
+	tree        []float64
+	maxPriority float64
+	rng         *rand.Rand
+	mu          sync.Mutex
+}
+
+func NewReplayBuffer(capacity int, prioritized bool) *ReplayBuffer {
+	b := &ReplayBuffer{Capacity: capacity, Prioritized: prioritized, rng: mnk.NewRand()}
+	b.init()
+	return b
+}
+
+// init (re)builds the sum tree from the stored priorities
+func (b *ReplayBuffer) init() {
+	b.tree = make([]float64, 2*b.Capacity)
+	b.maxPriority = 1
+	if len(b.Priorities) < b.Capacity {
+		p := make([]float64, b.Capacity)
+		copy(p, b.Priorities)
+		b.Priorities = p
+	}
+
+	for i, p := range b.Priorities {
+		if i < len(b.Items) {
+			b.setPriority(i, p)
+			b.maxPriority = math.Max(b.maxPriority, p)
+		}
+	}
+}
+
+// Len returns the number of stored transitions
+func (b *ReplayBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.Items)
+}
+
+// Add stores a transition, replacing the oldest one once the buffer is full
+func (b *ReplayBuffer) Add(t Transition) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := len(b.Items)
+	if i < b.Capacity {
+		b.Items = append(b.Items, t)
+	} else {
+		i = b.Pos
+		b.Items[i] = t
+		b.Pos = (b.Pos + 1) % b.Capacity
+	}
+
+	// New transitions are replayed at least once
+	b.setPriority(i, b.maxPriority)
+}
+
+// Sample returns the indexes of n transitions along with their importance
+// sampling weights
+func (b *ReplayBuffer) Sample(n int) (indexes []int, weights []float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.Items) == 0 {
+		return
+	}
+
+	indexes = make([]int, n)
+	weights = make([]float64, n)
+
+	if !b.Prioritized {
+		for s := range indexes {
+			indexes[s] = b.rng.Intn(len(b.Items))
+			weights[s] = 1
+		}
+		return
+	}
+
+	total := b.tree[1]
+	var maxWeight float64
+	for s := range indexes {
+		i := b.find(b.rng.Float64() * total)
+		indexes[s] = i
+
+		p := b.tree[b.Capacity+i] / total
+		weights[s] = math.Pow(float64(len(b.Items))*p, -replayBeta)
+		maxWeight = math.Max(maxWeight, weights[s])
+	}
+	for s := range weights {
+		weights[s] /= maxWeight
+	}
+	return
+}
+
+// Get returns the transition at index i
+func (b *ReplayBuffer) Get(i int) Transition {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Items[i]
+}
+
+// Update sets the priority of transition i from its latest TD error
+func (b *ReplayBuffer) Update(i int, tdError float64) {
+	if !b.Prioritized {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := math.Pow(math.Abs(tdError)+replayEpsilon, replayAlpha)
+	b.maxPriority = math.Max(b.maxPriority, p)
+	b.setPriority(i, p)
+}
+
+func (b *ReplayBuffer) setPriority(i int, p float64) {
+	b.Priorities[i] = p
+
+	node := b.Capacity + i
+	b.tree[node] = p
+	for node /= 2; node >= 1; node /= 2 {
+		b.tree[node] = b.tree[2*node] + b.tree[2*node+1]
+	}
+}
+
+// find returns the transition whose cumulative priority range holds v
+func (b *ReplayBuffer) find(v float64) int {
+	// Walk down from the root; the tree of an uneven capacity is not
+	// complete, so the walk may end past the leaves' start
+	node := 1
+	for node < b.Capacity {
+		left := 2 * node
+		if v < b.tree[left] || b.tree[left+1] == 0 {
+			node = left
+		} else {
+			v -= b.tree[left]
+			node = left + 1
+		}
+	}
+
+	i := node - b.Capacity
+	if i >= len(b.Items) {
+		i = len(b.Items) - 1
+	}
+	return i
+}
+
+// SaveToFile writes the buffer to given path
+func (b *ReplayBuffer) SaveToFile(path string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		fmt.Println("[error] Could not open writable replay file on disk!")
+		fmt.Println(err)
+		return false
+	}
+	defer file.Close()
+
+	enc := gob.NewEncoder(file)
+	err = enc.Encode(b)
+	if err != nil {
+		fmt.Println("[error] Encoding of replay buffer failed!")
+		fmt.Println(err)
+		return false
+	}
+
+	return true
+}
+
+// LoadFromFile reads the buffer from given path
+func (b *ReplayBuffer) LoadFromFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Println("[error] Could not open readable replay file on disk!")
+		fmt.Println(err)
+		return false
+	}
+	defer file.Close()
+
+	dec := gob.NewDecoder(file)
+	err = dec.Decode(b)
+	if err != nil {
+		fmt.Println("[error] Decoding of replay buffer failed!")
+		fmt.Println(err)
+		return false
+	}
+
+	b.init()
+	return true
+}
@@ -0,0 +1,65 @@
+package rl
+
+import (
+	"testing"
+
+	"mnkagent/mnk"
+)
+
+func TestReplayBufferCapacity(t *testing.T) {
+	b := NewReplayBuffer(3, false)
+	for i := 0; i < 5; i++ {
+		b.Add(Transition{Agent: 1, Reward: float64(i)})
+	}
+
+	if b.Len() != 3 {
+		t.Fatalf("Len(): Expected 3, actual %d", b.Len())
+	}
+
+	// The two oldest transitions are replaced
+	for i, want := range []float64{3, 4, 2} {
+		if r := b.Get(i).Reward; r != want {
+			t.Errorf("Get(%d): Expected reward %g, actual %g", i, want, r)
+		}
+	}
+}
+
+func TestReplayBufferPrioritized(t *testing.T) {
+	b := NewReplayBuffer(5, true)
+	for i := 0; i < 5; i++ {
+		b.Add(Transition{Agent: 1, Reward: float64(i)})
+		b.Update(i, 0)
+	}
+	b.Update(3, 100)
+
+	var hits int
+	indexes, weights := b.Sample(1000)
+	for s, i := range indexes {
+		if i == 3 {
+			hits++
+		}
+		if weights[s] <= 0 || weights[s] > 1 {
+			t.Errorf("Sample(): Expected weight %d in (0, 1], actual %g", s, weights[s])
+		}
+	}
+
+	if hits < 900 {
+		t.Errorf("Sample(): Expected the high priority transition at least 900 of 1000 times, actual %d", hits)
+	}
+}
+
+func TestTransitionAs(t *testing.T) {
+	tr := Transition{
+		Agent: 2,
+		State: mnk.MNKState{{1, 2}, {0, 0}},
+		Next:  mnk.MNKState{{1, 2}, {1, 0}},
+	}
+
+	got := tr.as(1)
+	if got.Agent != 1 || got.State[0][0] != 2 || got.State[0][1] != 1 || got.Next[1][0] != 2 {
+		t.Errorf("as(): Expected the marks to be swapped, actual %+v", got)
+	}
+	if tr.State[0][0] != 1 {
+		t.Error("as(): Expected the original transition to be unchanged")
+	}
+}
@@ -0,0 +1,767 @@
+package rl
+
+import (
+	"encoding/gob"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+
+	"mnkagent/mnk"
+)
+
+type RLAgent struct {
+	// Agent PlayerID
+	id int
+
+	// View settings
+	Sign string
+
+	// Game definition
+	m, n, k int
+
+	// RL parameters
+	Learning          bool
+	LearningRate      float64 //alpha
+	DiscountFactor    float64
+	ExplorationFactor float64 //epsilon
+
+	// Decay schedules overriding LearningRate and ExplorationFactor
+	AlphaSchedule   *Schedule
+	EpsilonSchedule *Schedule
+
+	// Learning rates decay from 1 with the updates of each key, down to the
+	// scheduled rate
+	CountBasedAlpha bool
+
+	// Exploration policy; Temperature is the softmax temperature when there
+	// is no schedule, UCBFactor the weight of the UCB1 bonus and
+	// OptimisticValue the initial value of unseen pairs
+	exploration         rlExploration
+	Temperature         float64
+	TemperatureSchedule *Schedule
+	UCBFactor           float64
+	OptimisticValue     float64
+
+	// Eligibility traces; a zero Lambda performs one-step updates
+	Lambda          float64
+	ReplacingTraces bool
+
+	// Potential-based reward shaping, disabled when nil
+	Shaping *RewardShaping
+
+	// Experience replay; transitions are learned from sampled mini-batches
+	// instead of in episode order when set
+	Replay      *ReplayBuffer
+	ReplayBatch int
+
+	// Collects the TD errors of the updates, if set
+	Errors *ErrorStats
+
+	// Update rule
+	algorithm   rlAlgorithm
+	afterstates bool
+
+	// States stash
+	knowledge *RLAgentKnowledge
+	episode   []rlStep // State-action history of the current episode
+	message   string
+
+	// Scratch environment used to evaluate rewards
+	env *mnk.MNKBoard
+
+	// Random generator of exploration and of Double Q-learning
+	rng *rand.Rand
+}
+
+// rlStep is a state-action pair of an episode along with its reward and
+// eligibility trace
+type rlStep struct {
+	state  mnk.MNKState
+	action mnk.MNKAction
+	reward float64
+	trace  float64
+}
+
+type RLAgentKnowledge struct {
+	Values           map[string]float64
+	ValuesB          map[string]float64 // Second table of Double Q-learning
+	Iterations       uint
+	randomDispersion []int
+
+	// Name of the update rule the values were learned with
+	Algorithm string
+
+	// Values are of afterstates rather than state-action pairs
+	Afterstates bool
+
+	// Decay schedules, so that resumed training continues where it left off
+	AlphaSchedule   *Schedule
+	EpsilonSchedule *Schedule
+
+	// Exploration policy and its parameters
+	Exploration         string
+	TemperatureSchedule *Schedule
+	UCBFactor           float64
+	OptimisticValue     float64
+
+	// Learning rates decay with the updates of each key
+	CountBasedAlpha bool
+
+	// Exploration and learning statistics of each key
+	Stats map[string]RLStats
+
+	// Visit counts of models saved before Stats, moved there on load
+	Visits map[string]uint
+
+	// Where the values are kept: in the model itself, or "log" for an
+	// append-only store next to it that may hold more than fits in memory
+	Store string
+	store *valueStore
+
+	// Keys initialized since the knowledge was loaded
+	added uint
+
+	// Guards the knowledge when agents play concurrent games
+	mu sync.Mutex
+}
+
+// RLStats tells a well explored key from one that was only initialized
+type RLStats struct {
+	Visits      uint // Times the pair was chosen
+	Updates     uint // Times its value was updated
+	LastUpdated uint // Model iteration of the last update
+}
+
+// NewRLAgent returns an agent that reads from and, when learning, writes to
+// the given knowledge
+func NewRLAgent(id int, sign string, m, n, k int, knowledge *RLAgentKnowledge, learn bool) (agent *RLAgent) {
+	agent = new(RLAgent)
+	agent.id = id
+	agent.Sign = sign
+
+	agent.m = m
+	agent.n = n
+	agent.k = k
+
+	// Default values
+	agent.Learning = learn
+	agent.LearningRate = 0.2
+	agent.DiscountFactor = 0.8
+	agent.ExplorationFactor = 0.25
+	agent.ReplacingTraces = true
+	agent.Temperature = 1
+	agent.UCBFactor = math.Sqrt2
+	agent.OptimisticValue = 1
+
+	agent.rng = mnk.NewRand()
+
+	// Initiate stash
+	agent.setKnowledge(knowledge)
+
+	agent.env, _ = mnk.NewMNKBoard(m, n, k) // Only ever given valid games
+
+	return
+}
+
+// Knowledge returns the knowledge the agent reads from and writes to
+func (agent *RLAgent) Knowledge() *RLAgentKnowledge {
+	return agent.knowledge
+}
+
+// setKnowledge makes the agent read from and write to the given knowledge
+func (agent *RLAgent) setKnowledge(k *RLAgentKnowledge) {
+	k.init(agent.m, agent.n)
+	agent.knowledge = k
+	agent.algorithm = Algorithms[k.AlgorithmName()]
+	agent.afterstates = k.Afterstates
+
+	agent.AlphaSchedule = k.AlphaSchedule
+	agent.EpsilonSchedule = k.EpsilonSchedule
+
+	agent.CountBasedAlpha = k.CountBasedAlpha
+	agent.exploration = Explorations[k.ExplorationName()]
+	agent.TemperatureSchedule = k.TemperatureSchedule
+	if k.UCBFactor > 0 {
+		agent.UCBFactor = k.UCBFactor
+	}
+	if k.OptimisticValue != 0 {
+		agent.OptimisticValue = k.OptimisticValue
+	}
+}
+
+// Freeze turns the agent into a greedy, non-learning player
+func (agent *RLAgent) Freeze() {
+	agent.Learning = false
+	agent.ExplorationFactor = 0
+	agent.EpsilonSchedule = nil
+	agent.exploration = epsilonGreedy{}
+}
+
+// CurrentLearningRate returns alpha for the model's current iteration
+func (agent *RLAgent) CurrentLearningRate() float64 {
+	if agent.AlphaSchedule == nil {
+		return agent.LearningRate
+	}
+	return agent.AlphaSchedule.Value(agent.knowledge.Trained())
+}
+
+// CurrentExplorationFactor returns epsilon for the model's current iteration
+func (agent *RLAgent) CurrentExplorationFactor() float64 {
+	if agent.EpsilonSchedule == nil {
+		return agent.ExplorationFactor
+	}
+	return agent.EpsilonSchedule.Value(agent.knowledge.Trained())
+}
+
+// temperature returns the softmax temperature for the model's current
+// iteration
+func (agent *RLAgent) temperature() float64 {
+	if agent.TemperatureSchedule == nil {
+		return agent.Temperature
+	}
+	return agent.TemperatureSchedule.Value(agent.knowledge.Trained())
+}
+
+func (agent *RLAgent) FetchMessage() (message string) {
+	message = agent.message
+	agent.message = ""
+	return
+}
+
+func (agent *RLAgent) FetchMove(state mnk.State, possibleActions []mnk.Action) (mnk.Action, error) {
+	// REVIEW: Rename to Move, and accept a function to do it, which returns the reward
+	var s mnk.MNKState = state.(mnk.MNKState)
+
+	action, greedy, message := agent.exploration.choose(agent, s, possibleActions)
+	agent.message = message
+	if !greedy {
+		agent.knowledge.disperse(action.Y*agent.m + action.X)
+	}
+
+	if agent.Learning {
+		agent.visit(s, action)
+		agent.learn(s, action, greedy)
+	}
+
+	agent.episode = append(agent.episode, rlStep{
+		state:  s, //.Clone()
+		action: action,
+		reward: agent.value(s, action),
+	})
+
+	return action, nil
+}
+
+func (agent *RLAgent) GameOver(state mnk.State) {
+	var s mnk.MNKState = state.(mnk.MNKState)
+
+	if agent.Learning {
+		agent.learn(s, Terminal, true)
+	}
+
+	// Restart for the next episode
+	agent.episode = agent.episode[:0]
+	agent.message = ""
+
+	if agent.Learning {
+		agent.knowledge.mu.Lock()
+		agent.knowledge.Iterations++
+		agent.knowledge.mu.Unlock()
+	}
+}
+
+func (agent *RLAgent) GetSign() string {
+	return agent.Sign
+}
+
+// greedy returns the empty cell with the highest value according to q, along
+// with its value
+func (agent *RLAgent) greedy(s mnk.MNKState, q func(mnk.MNKState, mnk.MNKAction) float64) (action mnk.MNKAction, qMax float64) {
+	var first = true
+	for i := range s {
+		for j := range s[i] {
+			if s[i][j] == 0 {
+				a := mnk.MNKAction{Y: i, X: j}
+				v := q(s, a)
+
+				if v > qMax || first {
+					qMax = v
+					action = a
+					first = false
+				}
+			}
+		}
+	}
+	return
+}
+
+// learn updates the values of the episode's state-action pairs given the
+// state that followed and the action chosen in it (Terminal once the game is
+// over). Earlier pairs are updated in proportion to their eligibility traces.
+func (agent *RLAgent) learn(s mnk.MNKState, chosen mnk.MNKAction, greedy bool) {
+	// Ignore an empty state-action (happens on first move)
+	if len(agent.episode) == 0 {
+		return
+	}
+	var prev = &agent.episode[len(agent.episode)-1]
+
+	var t = Transition{
+		Agent:      agent.id,
+		State:      prev.state,
+		Action:     prev.action,
+		Reward:     prev.reward,
+		Next:       s,
+		NextAction: chosen,
+		Terminal:   chosen == Terminal,
+	}
+
+	if agent.Shaping != nil {
+		var terms string
+		t.Shaping, terms = agent.Shaping.reward(t.State, t.Next, t.Terminal,
+			agent.m, agent.n, agent.k, agent.id, agent.DiscountFactor)
+		agent.message += ", " + terms
+	}
+
+	if agent.Replay != nil {
+		agent.Replay.Add(t)
+		agent.replay()
+		return
+	}
+
+	update, estimate := agent.algorithm.tables(agent)
+	var delta = agent.tdError(update, estimate, t)
+	var alpha = agent.CurrentLearningRate()
+	agent.Errors.Add(delta)
+
+	if agent.ReplacingTraces {
+		prev.trace = 1
+	} else {
+		prev.trace++
+	}
+
+	for i := range agent.episode {
+		step := &agent.episode[i]
+		if step.trace == 0 {
+			continue
+		}
+
+		agent.adjust(update, step.state, step.action, alpha, delta*step.trace)
+
+		step.trace *= agent.DiscountFactor * agent.Lambda
+	}
+
+	// Watkins's Q(lambda): an exploratory move breaks the greedy chain
+	if !greedy && agent.algorithm.offPolicy() {
+		for i := range agent.episode {
+			agent.episode[i].trace = 0
+		}
+	}
+}
+
+// tdError returns the difference between the target of the transition and the
+// current value of its state-action pair
+func (agent *RLAgent) tdError(update, estimate rlTable, t Transition) float64 {
+	var next float64
+	var reward = t.Reward
+	if t.Terminal && agent.afterstates {
+		// The outcome is the final afterstate's target
+		reward, next = agent.value(t.Next, Terminal), 0
+	} else if t.Terminal {
+		// Bypass the marshaller's action addition with (-1, -1)
+		next = agent.lookupIn(estimate, t.Next, Terminal)
+	} else {
+		next = agent.algorithm.estimate(agent, update, estimate, t.Next, t.NextAction)
+	}
+	reward += t.Shaping
+
+	var oldVal = agent.lookupIn(update, t.State, t.Action)
+
+	// REVIEW: Discount Factor may increase gradually (when estimating reward)
+	return reward + (agent.DiscountFactor * next) - oldVal
+}
+
+// replay updates the values of a mini-batch sampled from the replay buffer
+func (agent *RLAgent) replay() {
+	var alpha = agent.CurrentLearningRate()
+
+	indexes, weights := agent.Replay.Sample(agent.ReplayBatch)
+	for b, i := range indexes {
+		// Transitions of the other player are seen from this agent's side
+		t := agent.Replay.Get(i).as(agent.id)
+
+		update, estimate := agent.algorithm.tables(agent)
+		delta := agent.tdError(update, estimate, t)
+		agent.Errors.Add(delta)
+
+		agent.adjust(update, t.State, t.Action, alpha, weights[b]*delta)
+
+		agent.Replay.Update(i, delta)
+	}
+}
+
+// keyRate returns the learning rate of the given key; the knowledge must be
+// locked
+func (agent *RLAgent) keyRate(key string, alpha float64) float64 {
+	if !agent.CountBasedAlpha {
+		return alpha
+	}
+	return math.Max(alpha, 1/float64(agent.knowledge.Stats[key].Updates+1))
+}
+
+// actionValue returns the value the agent acts upon for the given state-action
+func (agent *RLAgent) actionValue(state mnk.MNKState, action mnk.MNKAction) float64 {
+	if agent.knowledge.ValuesB == nil {
+		return agent.lookup(state, action)
+	}
+	return (agent.lookupIn(rlTableA, state, action) +
+		agent.lookupIn(rlTableB, state, action)) / 2
+}
+
+// lookup returns the Q-value for the given state
+func (agent *RLAgent) lookup(state mnk.MNKState, action mnk.MNKAction) float64 {
+	return agent.lookupIn(rlTableA, state, action)
+}
+
+// lookupIn returns the Q-value for the given state from the given table
+func (agent *RLAgent) lookupIn(table rlTable, state mnk.MNKState, action mnk.MNKAction) float64 {
+	var mState = agent.Key(state, action) // Marshalled state
+
+	agent.knowledge.mu.Lock()
+	defer agent.knowledge.mu.Unlock()
+	return agent.resident(table, mState, state, action)
+}
+
+// adjust adds amount, scaled by the learning rate of the pair, to the value
+// of the given state-action and records the update
+func (agent *RLAgent) adjust(table rlTable, state mnk.MNKState, action mnk.MNKAction, alpha, amount float64) {
+	var mState = agent.Key(state, action)
+
+	agent.knowledge.mu.Lock()
+	defer agent.knowledge.mu.Unlock()
+
+	val := agent.resident(table, mState, state, action)
+	agent.knowledge.table(table)[mState] = val + agent.keyRate(mState, alpha)*amount
+	agent.knowledge.updated(mState)
+	agent.knowledge.touch(table, mState)
+}
+
+// visit records a choice of the given state-action
+func (agent *RLAgent) visit(state mnk.MNKState, action mnk.MNKAction) {
+	var mState = agent.Key(state, action)
+
+	agent.knowledge.mu.Lock()
+	defer agent.knowledge.mu.Unlock()
+
+	agent.resident(rlTableA, mState, state, action)
+	stats := agent.knowledge.Stats[mState]
+	stats.Visits++
+	agent.knowledge.Stats[mState] = stats
+	agent.knowledge.touch(rlTableA, mState)
+}
+
+// resident returns the value of the key from the given table, bringing it
+// into memory from the store, or initializing it, if needed. Agents that do
+// not learn only read the initial value of unseen keys. The knowledge must be
+// locked.
+func (agent *RLAgent) resident(table rlTable, key string, state mnk.MNKState, action mnk.MNKAction) float64 {
+	if val, ok := agent.knowledge.table(table)[key]; ok {
+		return val
+	}
+
+	val, ok := agent.knowledge.fault(table, key)
+	if !ok {
+		val = agent.value(state, action)
+		if val == 0 && agent.knowledge.ExplorationName() == "optimistic" {
+			val = agent.OptimisticValue
+		}
+		if !agent.Learning {
+			return val
+		}
+		if table == rlTableA {
+			agent.knowledge.added++
+		}
+	}
+	agent.knowledge.insert(table, key, val)
+	return val
+}
+
+// Key returns the table key of the given state-action pair
+func (agent *RLAgent) Key(state mnk.MNKState, action mnk.MNKAction) string {
+	if agent.afterstates {
+		return MarshallAfterstate(agent.id, state, action)
+	}
+	return MarshallState(agent.id, state, action)
+}
+
+// value returns the reward for the given state
+func (agent *RLAgent) value(state mnk.MNKState, action mnk.MNKAction) float64 {
+	agent.env.SetState(state)
+
+	if action != Terminal {
+		switch agent.env.EvaluateAction(agent.id, action) {
+		case 1: // Agent won
+			return 1
+		case 0: // Game goes on
+			return 0
+		case -1: // Draw
+			return -0.5
+		}
+	}
+
+	switch agent.env.Evaluate() {
+	case agent.id: // Agent won
+		return 1
+	case 0: // Game goes on
+		return 0
+	case -1: // Draw
+		return -0.5
+	default: // Agent lost
+		return -1
+	}
+}
+
+// init prepares the knowledge stash for an m by n board
+func (k *RLAgentKnowledge) init(m, n int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.Values == nil {
+		k.Values = make(map[string]float64)
+	}
+	if k.Stats == nil {
+		k.Stats = make(map[string]RLStats)
+	}
+	if k.ValuesB == nil && k.AlgorithmName() == "double-q" {
+		k.ValuesB = make(map[string]float64)
+	}
+
+	if len(k.randomDispersion) < m*n {
+		var tmp []int = make([]int, m*n)
+		copy(tmp, k.randomDispersion)
+		k.randomDispersion = tmp
+	}
+}
+
+// AlgorithmName returns the name of the update rule, defaulting to Q-learning
+func (k *RLAgentKnowledge) AlgorithmName() string {
+	if k.Algorithm == "" {
+		return "q"
+	}
+	return k.Algorithm
+}
+
+// ExplorationName returns the name of the exploration policy, defaulting to
+// epsilon-greedy
+func (k *RLAgentKnowledge) ExplorationName() string {
+	if k.Exploration == "" {
+		return "epsilon-greedy"
+	}
+	return k.Exploration
+}
+
+// visits returns the number of times the given key was chosen
+func (k *RLAgentKnowledge) visits(key string) uint {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.Stats[key].Visits
+}
+
+// updated records an update of the given key; the knowledge must be locked
+func (k *RLAgentKnowledge) updated(key string) {
+	stats := k.Stats[key]
+	stats.Updates++
+	stats.LastUpdated = k.Iterations
+	k.Stats[key] = stats
+}
+
+// Snapshot returns a copy of the values and settings of the knowledge; with a
+// memory cap, only the values in memory are copied
+func (k *RLAgentKnowledge) Snapshot() *RLAgentKnowledge {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	c := &RLAgentKnowledge{
+		Values:              maps.Clone(k.Values),
+		ValuesB:             maps.Clone(k.ValuesB),
+		Iterations:          k.Iterations,
+		Algorithm:           k.Algorithm,
+		Afterstates:         k.Afterstates,
+		Exploration:         k.Exploration,
+		UCBFactor:           k.UCBFactor,
+		OptimisticValue:     k.OptimisticValue,
+		TemperatureSchedule: k.TemperatureSchedule,
+		CountBasedAlpha:     k.CountBasedAlpha,
+	}
+	return c
+}
+
+// Learned returns the number of keys initialized since loading
+func (k *RLAgentKnowledge) Learned() uint {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.added
+}
+
+// Size returns the number of values in memory
+func (k *RLAgentKnowledge) Size() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.Values)
+}
+
+// Dispersion returns the number of random moves on each cell
+func (k *RLAgentKnowledge) Dispersion() []int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]int(nil), k.randomDispersion...)
+}
+
+// Trained returns the number of iterations the knowledge was trained for
+func (k *RLAgentKnowledge) Trained() uint {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.Iterations
+}
+
+// disperse records a random move on the given cell
+func (k *RLAgentKnowledge) disperse(cell int) {
+	k.mu.Lock()
+	k.randomDispersion[cell]++
+	k.mu.Unlock()
+}
+
+// storeKnowledge writes the knowledge map to given path
+func (k *RLAgentKnowledge) SaveToFile(path string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// Values in the store are appended to it instead of being rewritten
+	if k.store != nil && k.store.owns(path) {
+		if err := k.store.flush(k); err != nil {
+			fmt.Println("[error] Could not write values to the store!")
+			fmt.Println(err)
+			return false
+		}
+
+		values, valuesB, stats := k.Values, k.ValuesB, k.Stats
+		k.Values, k.ValuesB, k.Stats = nil, nil, nil
+		defer func() { k.Values, k.ValuesB, k.Stats = values, valuesB, stats }()
+	} else if k.store != nil {
+		// Other files get all values in themselves, the store is left alone
+		all, allB, allStats, err := k.merged()
+		if err != nil {
+			fmt.Println("[error] Could not read values from the store!")
+			fmt.Println(err)
+			return false
+		}
+
+		values, valuesB, stats, store := k.Values, k.ValuesB, k.Stats, k.Store
+		k.Values, k.ValuesB, k.Stats, k.Store = all, allB, allStats, ""
+		defer func() { k.Values, k.ValuesB, k.Stats, k.Store = values, valuesB, stats, store }()
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		fmt.Println("[error] Could not open writable knowledge file on disk!")
+		fmt.Println(err)
+		return false
+	}
+	defer file.Close()
+
+	enc := gob.NewEncoder(file)
+	err = enc.Encode(k)
+	if err != nil {
+		fmt.Println("[error] Encoding of knowledge failed!")
+		fmt.Println(err)
+		return false
+	}
+
+	return true
+}
+
+// retrieveKnowledge reads the knowledge from given path to knowledge map
+func (k *RLAgentKnowledge) LoadFromFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Println("[error] Could not open readable knowledge file on disk!")
+		fmt.Println(err)
+		return false
+	}
+	defer file.Close()
+
+	dec := gob.NewDecoder(file)
+	err = dec.Decode(k)
+	if err != nil {
+		fmt.Println("[error] Decoding of knowledge failed!")
+		fmt.Println(err)
+		return false
+	}
+
+	// Older models count visits only
+	if len(k.Visits) > 0 {
+		if k.Stats == nil {
+			k.Stats = make(map[string]RLStats, len(k.Visits))
+		}
+		for key, v := range k.Visits {
+			stats := k.Stats[key]
+			stats.Visits += v
+			k.Stats[key] = stats
+		}
+		k.Visits = nil
+	}
+
+	if k.Store == "log" {
+		if err = k.OpenStore(path+".values", 0); err != nil {
+			fmt.Println("[error] Could not open the value store of the knowledge!")
+			fmt.Println(err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func MarshallState(agentID int, state mnk.MNKState, action mnk.MNKAction) (m string) {
+	for i := range state {
+		for j := range state[i] {
+			// Include action in state
+			if i == action.Y && j == action.X {
+				m += "X"
+				continue
+			}
+
+			switch state[i][j] {
+			case 0:
+				m += "-"
+			case agentID:
+				m += "X"
+			default:
+				m += "O"
+			}
+		}
+	}
+	return
+}
+
+// MarshallAfterstate encodes the board after mover plays action. Marks are
+// written from the mover's perspective ("x" own, "o" opponent's, "." empty),
+// so the same afterstate reached as X or as O shares a single key.
+func MarshallAfterstate(mover int, state mnk.MNKState, action mnk.MNKAction) string {
+	var m = make([]byte, 0, len(state)*len(state[0]))
+	for i := range state {
+		for j := range state[i] {
+			switch {
+			case i == action.Y && j == action.X, state[i][j] == mover:
+				m = append(m, 'x')
+			case state[i][j] == 0:
+				m = append(m, '.')
+			default:
+				m = append(m, 'o')
+			}
+		}
+	}
+	return string(m)
+}
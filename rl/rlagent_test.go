@@ -0,0 +1,244 @@
+package rl
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"mnkagent/mnk"
+)
+
+func TestMarshallAfterstate(t *testing.T) {
+	// The same position with the roles of X and O swapped
+	var asX = mnk.MNKState{{1, 2, 0}, {0, 1, 0}, {2, 0, 0}}
+	var asO = mnk.MNKState{{2, 1, 0}, {0, 2, 0}, {1, 0, 0}}
+	var action = mnk.MNKAction{X: 2, Y: 2}
+
+	x := MarshallAfterstate(1, asX, action)
+	o := MarshallAfterstate(2, asO, action)
+
+	if x != "xo..x.o.x" {
+		t.Errorf("MarshallAfterstate(): Expected %q, actual %q", "xo..x.o.x", x)
+	}
+	if x != o {
+		t.Errorf("MarshallAfterstate(): Expected equal keys for X and O, actual %q and %q", x, o)
+	}
+}
+
+func TestRLAgentAfterstateTerminal(t *testing.T) {
+	var kw RLAgentKnowledge
+	kw.Afterstates = true
+
+	agent := NewRLAgent(1, "X", 3, 3, 3, &kw, true)
+	agent.ExplorationFactor = 0
+	agent.LearningRate = 1
+
+	// X completes the top row
+	var s = mnk.MNKState{{1, 1, 0}, {2, 2, 0}, {0, 0, 0}}
+	a, _ := agent.FetchMove(s, []mnk.Action{mnk.MNKAction{X: 2, Y: 0}})
+	if a != (mnk.MNKAction{X: 2, Y: 0}) {
+		t.Fatalf("FetchMove(): Expected the winning move, actual %v", a)
+	}
+
+	s = s.Clone()
+	s[0][2] = 1
+	agent.GameOver(s)
+
+	if v := kw.Values["xxxoo...."]; v != 1 {
+		t.Errorf("GameOver(): Expected the winning afterstate to be worth 1, actual %g", v)
+	}
+}
+
+func TestRLAgentUCBTriesUntriedActions(t *testing.T) {
+	var kw RLAgentKnowledge
+	kw.Exploration = "ucb"
+
+	agent := NewRLAgent(1, "X", 3, 3, 3, &kw, true)
+
+	// Every action is tried once before any is repeated
+	var s = mnk.MNKState{{1, 2, 0}, {2, 1, 0}, {1, 2, 0}}
+	var seen = make(map[mnk.MNKAction]bool)
+	for i := 0; i < 3; i++ {
+		a, _ := agent.FetchMove(s, nil)
+		seen[a.(mnk.MNKAction)] = true
+	}
+	agent.GameOver(s)
+
+	if len(seen) != 3 {
+		t.Errorf("FetchMove(): Expected 3 distinct actions, actual %v", seen)
+	}
+}
+
+func TestRLAgentSoftmaxZeroTemperature(t *testing.T) {
+	var kw RLAgentKnowledge
+	kw.Exploration = "softmax"
+
+	agent := NewRLAgent(1, "X", 3, 3, 3, &kw, false)
+	agent.Temperature = 0
+
+	// X completes the top row
+	var s = mnk.MNKState{{1, 1, 0}, {2, 2, 0}, {0, 0, 0}}
+	for i := 0; i < 10; i++ {
+		a, _ := agent.FetchMove(s, nil)
+		if a != (mnk.MNKAction{X: 2, Y: 0}) {
+			t.Fatalf("FetchMove(): Expected the winning move, actual %v", a)
+		}
+	}
+}
+
+func TestRLAgentKnowledgeLoadsVisits(t *testing.T) {
+	// Models saved before per-key statistics count visits only
+	var old = RLAgentKnowledge{
+		Values: map[string]float64{"xo.......": 0.5},
+		Visits: map[string]uint{"xo.......": 7},
+	}
+	var path = filepath.Join(t.TempDir(), "old.kw")
+	if !old.SaveToFile(path) {
+		t.Fatal("SaveToFile(): Failed")
+	}
+
+	var kw RLAgentKnowledge
+	if !kw.LoadFromFile(path) {
+		t.Fatal("LoadFromFile(): Failed")
+	}
+	if kw.Stats["xo......."].Visits != 7 || kw.Visits != nil {
+		t.Errorf("LoadFromFile(): Expected the visits in Stats, actual %v and %v", kw.Stats, kw.Visits)
+	}
+}
+
+func TestRLAgentCountBasedAlpha(t *testing.T) {
+	var kw RLAgentKnowledge
+	kw.CountBasedAlpha = true
+
+	agent := NewRLAgent(1, "X", 3, 3, 3, &kw, true)
+	agent.LearningRate = 0.1
+
+	kw.Stats["fresh"] = RLStats{}
+	kw.Stats["known"] = RLStats{Updates: 99}
+	if r := agent.keyRate("fresh", 0.1); r != 1 {
+		t.Errorf("keyRate(): Expected 1 for a fresh key, actual %g", r)
+	}
+	if r := agent.keyRate("known", 0.1); r != 0.1 {
+		t.Errorf("keyRate(): Expected the base rate for a known key, actual %g", r)
+	}
+}
+
+func TestRLAgentFrozenDoesNotInsert(t *testing.T) {
+	var kw RLAgentKnowledge
+	kw.Exploration = "optimistic"
+
+	agent := NewRLAgent(1, "X", 3, 3, 3, &kw, false)
+	agent.Freeze()
+
+	var s = mnk.MNKState{{1, 2, 0}, {2, 1, 0}, {0, 0, 0}}
+	agent.FetchMove(s, nil)
+	agent.GameOver(s)
+
+	if len(kw.Values) != 0 || kw.Learned() != 0 {
+		t.Errorf("FetchMove(): Expected no values to be added, actual %d (%d learned)",
+			len(kw.Values), kw.Learned())
+	}
+
+	// Unseen pairs read as optimistic even once frozen
+	a := mnk.MNKAction{X: 2, Y: 0}
+	if v := agent.lookup(s, a); v != agent.OptimisticValue {
+		t.Errorf("lookup(): Expected %g for an unseen pair, actual %g", agent.OptimisticValue, v)
+	}
+}
+
+// playTraceEpisode plays two greedy moves as X, the second one winning, and
+// returns the value of the first pair afterwards
+func playTraceEpisode(lambda float64) float64 {
+	var kw RLAgentKnowledge
+	agent := NewRLAgent(1, "X", 3, 3, 3, &kw, true)
+	agent.ExplorationFactor = 0
+	agent.LearningRate = 0.5
+	agent.Lambda = lambda
+
+	var s0 = mnk.MNKState{{0, 2, 0}, {1, 2, 0}, {0, 0, 1}}
+	a0, _ := agent.FetchMove(s0, nil)
+
+	var s1 = mnk.MNKState{{1, 2, 2}, {1, 2, 0}, {0, 0, 1}}
+	agent.FetchMove(s1, nil)
+	var final = s1.Clone()
+	final[2][0] = 1
+	agent.GameOver(final)
+
+	return kw.Values[agent.Key(s0, a0.(mnk.MNKAction))]
+}
+
+func TestRLAgentTracesPropagateRewards(t *testing.T) {
+	// The first move is worth 0.5*(0.8*1) after the second one, then the
+	// final error of 1+0.8*1-1 reaches it through its trace 0.8*lambda
+	if v := playTraceEpisode(0); math.Abs(v-0.4) > 1e-9 {
+		t.Errorf("GameOver(): Expected 0.4 without traces, actual %g", v)
+	}
+	if v, want := playTraceEpisode(0.5), 0.4+0.5*0.8*0.8*0.5; math.Abs(v-want) > 1e-9 {
+		t.Errorf("GameOver(): Expected the reward to reach the first move, %g, actual %g", want, v)
+	}
+}
+
+func TestRLAgentTraces(t *testing.T) {
+	var s = mnk.MNKState{{1, 2, 0}, {0, 0, 0}, {0, 0, 0}}
+	var next = mnk.MNKState{{1, 2, 1}, {2, 0, 0}, {0, 0, 0}}
+	var a = mnk.MNKAction{X: 2, Y: 0}
+
+	for _, tc := range []struct {
+		algorithm string
+		replacing bool
+		greedy    bool
+		trace     float64 // Of the first step afterwards
+		last      float64 // Of the last step afterwards
+	}{
+		{"q", true, true, 0.5 * 0.8 * 0.9, 0.8 * 0.9},
+		{"q", false, true, 0.5 * 0.8 * 0.9, 1.5 * 0.8 * 0.9},
+		{"q", true, false, 0, 0},                           // Watkins's cut
+		{"sarsa", true, false, 0.5 * 0.8 * 0.9, 0.8 * 0.9}, // On-policy, no cut
+	} {
+		var kw = RLAgentKnowledge{Algorithm: tc.algorithm}
+		agent := NewRLAgent(1, "X", 3, 3, 3, &kw, true)
+		agent.Lambda = 0.9
+		agent.ReplacingTraces = tc.replacing
+
+		// The last step already carries a trace, as a revisited pair would
+		agent.episode = []rlStep{
+			{state: s, action: mnk.MNKAction{X: 0, Y: 1}, trace: 0.5},
+			{state: s, action: a, trace: 0.5},
+		}
+		agent.learn(next, mnk.MNKAction{X: 1, Y: 1}, tc.greedy)
+
+		if first, last := agent.episode[0].trace, agent.episode[1].trace; math.Abs(first-tc.trace) > 1e-9 || math.Abs(last-tc.last) > 1e-9 {
+			t.Errorf("learn(): Expected traces %g and %g with %s, replacing %t, greedy %t, actual %g and %g",
+				tc.trace, tc.last, tc.algorithm, tc.replacing, tc.greedy, first, last)
+		}
+	}
+}
+
+func TestRLAgentCollectsErrors(t *testing.T) {
+	var kw RLAgentKnowledge
+	var errs ErrorStats
+	agent := NewRLAgent(1, "X", 3, 3, 3, &kw, true)
+	agent.ExplorationFactor = 0
+	agent.Errors = &errs
+
+	// X completes the top row, worth the discounted win of 0.8 over the
+	// unseen value of 0
+	var s = mnk.MNKState{{1, 1, 0}, {2, 2, 0}, {0, 0, 0}}
+	agent.FetchMove(s, []mnk.Action{mnk.MNKAction{X: 2, Y: 0}})
+	s = s.Clone()
+	s[0][2] = 1
+	agent.GameOver(s)
+
+	if mean, count := errs.Take(); math.Abs(mean-0.8) > 1e-9 || count != 1 {
+		t.Errorf("GameOver(): Expected a TD error of 0.8, actual %g of %d", mean, count)
+	}
+
+	// Agents without a collector discard their errors
+	other := NewRLAgent(1, "X", 3, 3, 3, &RLAgentKnowledge{}, true)
+	other.ExplorationFactor = 0
+	other.FetchMove(mnk.MNKState{{1, 1, 0}, {2, 2, 0}, {0, 0, 0}}, []mnk.Action{mnk.MNKAction{X: 2, Y: 0}})
+	other.GameOver(s)
+	if _, count := errs.Take(); count != 0 {
+		t.Errorf("GameOver(): Expected no errors of another agent, actual %d", count)
+	}
+}
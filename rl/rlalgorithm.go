@@ -0,0 +1,106 @@
+package rl
+
+import "mnkagent/mnk"
+
+// Terminal is the action that marks the end of an episode
+var Terminal = mnk.MNKAction{Y: -1, X: -1}
+
+// rlTable identifies one of the value tables of the knowledge
+type rlTable byte
+
+const (
+	rlTableA rlTable = iota
+	rlTableB         // Second table of Double Q-learning
+)
+
+// rlAlgorithm is a rule for updating a state-action value towards its target
+type rlAlgorithm interface {
+	// tables returns the table to update and the one to estimate from
+	tables(agent *RLAgent) (update, estimate rlTable)
+
+	// estimate returns the value of the next state s, in which the agent
+	// chose the given action
+	estimate(agent *RLAgent, update, estimate rlTable, s mnk.MNKState, chosen mnk.MNKAction) float64
+
+	// offPolicy reports whether the estimate assumes greedy play, in which
+	// case eligibility traces are cut after exploratory moves
+	offPolicy() bool
+}
+
+// Algorithms lists the available update rules by name
+var Algorithms = map[string]rlAlgorithm{
+	"q":              qLearning{},
+	"sarsa":          sarsa{},
+	"expected-sarsa": expectedSarsa{},
+	"double-q":       doubleQLearning{},
+}
+
+// qLearning bootstraps off-policy from the best action of the next state
+type qLearning struct{}
+
+func (qLearning) tables(agent *RLAgent) (update, estimate rlTable) {
+	return rlTableA, rlTableA
+}
+
+func (qLearning) estimate(agent *RLAgent, update, estimate rlTable, s mnk.MNKState, _ mnk.MNKAction) float64 {
+	// The update table picks the action, the estimate table values it
+	best, _ := agent.greedy(s, func(s mnk.MNKState, a mnk.MNKAction) float64 {
+		return agent.lookupIn(update, s, a)
+	})
+	return agent.lookupIn(estimate, s, best)
+}
+
+func (qLearning) offPolicy() bool {
+	return true
+}
+
+// sarsa bootstraps on-policy from the action actually chosen next
+type sarsa struct{}
+
+func (sarsa) tables(agent *RLAgent) (update, estimate rlTable) {
+	return rlTableA, rlTableA
+}
+
+func (sarsa) estimate(agent *RLAgent, _, estimate rlTable, s mnk.MNKState, chosen mnk.MNKAction) float64 {
+	return agent.lookupIn(estimate, s, chosen)
+}
+
+func (sarsa) offPolicy() bool {
+	return false
+}
+
+// expectedSarsa bootstraps from the expected value under the agent's
+// exploration policy
+type expectedSarsa struct{}
+
+func (expectedSarsa) tables(agent *RLAgent) (update, estimate rlTable) {
+	return rlTableA, rlTableA
+}
+
+func (expectedSarsa) estimate(agent *RLAgent, _, estimate rlTable, s mnk.MNKState, _ mnk.MNKAction) float64 {
+	var actions = s.EmptyCells()
+	var expected float64
+	for i, p := range agent.exploration.policy(agent, s, actions) {
+		if p > 0 {
+			expected += p * agent.lookupIn(estimate, s, actions[i])
+		}
+	}
+	return expected
+}
+
+func (expectedSarsa) offPolicy() bool {
+	return false
+}
+
+// doubleQLearning keeps two tables, each valuing the other's best action, to
+// reduce the maximization bias of Q-learning
+type doubleQLearning struct {
+	qLearning
+}
+
+func (doubleQLearning) tables(agent *RLAgent) (update, estimate rlTable) {
+	if agent.rng.Intn(2) == 0 {
+		return rlTableA, rlTableB
+	}
+	return rlTableB, rlTableA
+}
@@ -0,0 +1,106 @@
+package rl
+
+import (
+	"math"
+	"testing"
+
+	"mnkagent/mnk"
+)
+
+// Two moves remain in next, neither of which ends the game
+var (
+	updateState = mnk.MNKState{{1, 2, 1}, {1, 2, 0}, {2, 1, 0}}
+	updateGood  = mnk.MNKAction{X: 2, Y: 1}
+	updateBad   = mnk.MNKAction{X: 2, Y: 2}
+)
+
+// newUpdateAgent returns a learning agent valuing the good move 0.4 and the
+// bad one -0.2
+func newUpdateAgent(algorithm, exploration string) *RLAgent {
+	var kw = RLAgentKnowledge{Algorithm: algorithm, Exploration: exploration}
+	agent := NewRLAgent(1, "X", 3, 3, 3, &kw, true)
+	kw.Values[agent.Key(updateState, updateGood)] = 0.4
+	kw.Values[agent.Key(updateState, updateBad)] = -0.2
+	return agent
+}
+
+func TestSarsaEstimate(t *testing.T) {
+	agent := newUpdateAgent("sarsa", "")
+
+	// The action actually chosen is bootstrapped from, not the best one
+	if v := agent.algorithm.estimate(agent, rlTableA, rlTableA, updateState, updateBad); v != -0.2 {
+		t.Errorf("estimate(): Expected the chosen action's value -0.2, actual %g", v)
+	}
+
+	var kw = agent.knowledge
+	var prev = mnk.MNKState{{1, 2, 1}, {0, 2, 0}, {2, 1, 0}}
+	var played = mnk.MNKAction{X: 0, Y: 1}
+	kw.Values[agent.Key(prev, played)] = 0.1
+
+	delta := agent.tdError(rlTableA, rlTableA, Transition{
+		Agent: 1, State: prev, Action: played, Next: updateState, NextAction: updateBad,
+	})
+	if want := 0.8*-0.2 - 0.1; math.Abs(delta-want) > 1e-9 {
+		t.Errorf("tdError(): Expected %g, actual %g", want, delta)
+	}
+}
+
+func TestQLearningEstimate(t *testing.T) {
+	agent := newUpdateAgent("q", "")
+	if v := agent.algorithm.estimate(agent, rlTableA, rlTableA, updateState, updateBad); v != 0.4 {
+		t.Errorf("estimate(): Expected the best action's value 0.4, actual %g", v)
+	}
+}
+
+func TestExpectedSarsaEstimate(t *testing.T) {
+	agent := newUpdateAgent("expected-sarsa", "")
+	agent.ExplorationFactor = 0.5
+	v := agent.algorithm.estimate(agent, rlTableA, rlTableA, updateState, updateBad)
+	if want := 0.75*0.4 + 0.25*-0.2; math.Abs(v-want) > 1e-9 {
+		t.Errorf("estimate(): Expected %g under epsilon-greedy, actual %g", want, v)
+	}
+
+	// The expectation follows the agent's own policy
+	agent = newUpdateAgent("expected-sarsa", "softmax")
+	v = agent.algorithm.estimate(agent, rlTableA, rlTableA, updateState, updateBad)
+	good, bad := math.Exp(0.4), math.Exp(-0.2)
+	if want := (0.4*good - 0.2*bad) / (good + bad); math.Abs(v-want) > 1e-9 {
+		t.Errorf("estimate(): Expected %g under softmax, actual %g", want, v)
+	}
+
+	agent = newUpdateAgent("expected-sarsa", "optimistic")
+	if v = agent.algorithm.estimate(agent, rlTableA, rlTableA, updateState, updateBad); v != 0.4 {
+		t.Errorf("estimate(): Expected the greedy value 0.4 under optimistic, actual %g", v)
+	}
+}
+
+func TestDoubleQLearning(t *testing.T) {
+	agent := newUpdateAgent("double-q", "")
+	var kw = agent.knowledge
+	kw.ValuesB[agent.Key(updateState, updateGood)] = 0.1
+	kw.ValuesB[agent.Key(updateState, updateBad)] = 0.9
+
+	// Each table picks the action the other one values
+	if v := agent.algorithm.estimate(agent, rlTableA, rlTableB, updateState, updateBad); v != 0.1 {
+		t.Errorf("estimate(): Expected B's value of A's best action 0.1, actual %g", v)
+	}
+	if v := agent.algorithm.estimate(agent, rlTableB, rlTableA, updateState, updateBad); v != -0.2 {
+		t.Errorf("estimate(): Expected A's value of B's best action -0.2, actual %g", v)
+	}
+
+	// Both tables take turns being updated
+	var seen = make(map[[2]rlTable]bool)
+	for i := 0; i < 100; i++ {
+		update, estimate := agent.algorithm.tables(agent)
+		seen[[2]rlTable{update, estimate}] = true
+	}
+	if len(seen) != 2 || !seen[[2]rlTable{rlTableA, rlTableB}] || !seen[[2]rlTable{rlTableB, rlTableA}] {
+		t.Errorf("tables(): Expected A/B and B/A, actual %v", seen)
+	}
+
+	// Only the update table changes
+	agent.adjust(rlTableB, updateState, updateGood, 1, 0.5)
+	if a, b := kw.Values[agent.Key(updateState, updateGood)], kw.ValuesB[agent.Key(updateState, updateGood)]; a != 0.4 || b != 0.6 {
+		t.Errorf("adjust(): Expected 0.4 in A and 0.6 in B, actual %g and %g", a, b)
+	}
+}
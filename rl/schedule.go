@@ -0,0 +1,103 @@
+package rl
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Schedule describes how a parameter changes over the model's iterations
+type Schedule struct {
+	Kind    string  // constant|linear|exponential|inverse-time|step
+	Initial float64 // Value at iteration zero
+	Final   float64 // Target of linear, floor of exponential schedules
+	Rate    float64 // Decay factor of exponential, inverse-time and step schedules
+	Steps   uint    // Horizon of linear, interval of step schedules
+}
+
+// Value returns the parameter's value at the given iteration
+func (s *Schedule) Value(iteration uint) float64 {
+	t := float64(iteration)
+
+	switch s.Kind {
+	case "linear":
+		if s.Steps == 0 || iteration >= s.Steps {
+			return s.Final
+		}
+		return s.Initial + (s.Final-s.Initial)*t/float64(s.Steps)
+
+	case "exponential":
+		return math.Max(s.Final, s.Initial*math.Pow(s.Rate, t))
+
+	case "inverse-time":
+		return s.Initial / (1 + s.Rate*t)
+
+	case "step":
+		if s.Steps == 0 {
+			return s.Initial
+		}
+		return s.Initial * math.Pow(s.Rate, float64(iteration/s.Steps))
+	}
+
+	// Constant
+	return s.Initial
+}
+
+func (s *Schedule) String() string {
+	switch s.Kind {
+	case "linear":
+		return fmt.Sprintf("linear:initial=%g,final=%g,steps=%d", s.Initial, s.Final, s.Steps)
+	case "exponential":
+		return fmt.Sprintf("exponential:initial=%g,rate=%g,final=%g", s.Initial, s.Rate, s.Final)
+	case "inverse-time":
+		return fmt.Sprintf("inverse-time:initial=%g,rate=%g", s.Initial, s.Rate)
+	case "step":
+		return fmt.Sprintf("step:initial=%g,rate=%g,steps=%d", s.Initial, s.Rate, s.Steps)
+	}
+	return fmt.Sprintf("constant:initial=%g", s.Initial)
+}
+
+// ParseSchedule reads a schedule written as kind:key=value,... where keys are
+// initial, final, rate and steps, e.g. "linear:initial=0.2,final=0.01,steps=1e5"
+func ParseSchedule(spec string) (*Schedule, error) {
+	kind, params, _ := strings.Cut(spec, ":")
+
+	var s = &Schedule{Kind: kind}
+	switch kind {
+	case "constant", "linear", "exponential", "inverse-time", "step":
+	default:
+		return nil, fmt.Errorf("schedule: unknown kind %q", kind)
+	}
+
+	if params == "" {
+		return nil, fmt.Errorf("schedule: missing parameters in %q", spec)
+	}
+
+	for _, p := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(p, "=")
+		if !ok {
+			return nil, fmt.Errorf("schedule: invalid parameter %q", p)
+		}
+
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("schedule: invalid value for %s: %q", key, value)
+		}
+
+		switch key {
+		case "initial":
+			s.Initial = v
+		case "final":
+			s.Final = v
+		case "rate":
+			s.Rate = v
+		case "steps":
+			s.Steps = uint(v)
+		default:
+			return nil, fmt.Errorf("schedule: unknown parameter %q", key)
+		}
+	}
+
+	return s, nil
+}
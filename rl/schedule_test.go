@@ -0,0 +1,51 @@
+package rl
+
+import (
+	"math"
+	"testing"
+)
+
+var ScheduleTable = []struct {
+	spec      string
+	iteration uint
+	expected  float64
+}{
+	{"constant:initial=0.2", 1000, 0.2},
+	{"linear:initial=0.2,final=0.1,steps=100", 0, 0.2},
+	{"linear:initial=0.2,final=0.1,steps=100", 50, 0.15},
+	{"linear:initial=0.2,final=0.1,steps=100", 200, 0.1},
+	{"exponential:initial=1,rate=0.5,final=0.1", 2, 0.25},
+	{"exponential:initial=1,rate=0.5,final=0.1", 10, 0.1},
+	{"inverse-time:initial=1,rate=0.5", 2, 0.5},
+	{"step:initial=1,rate=0.5,steps=10", 9, 1},
+	{"step:initial=1,rate=0.5,steps=10", 25, 0.25},
+}
+
+func TestScheduleValue(t *testing.T) {
+	for _, a := range ScheduleTable {
+		s, err := ParseSchedule(a.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", a.spec, err)
+			continue
+		}
+
+		if r := s.Value(a.iteration); math.Abs(r-a.expected) > 1e-9 {
+			t.Errorf("Value(%d): Expected %g for %s, actual %g",
+				a.iteration, a.expected, a.spec, r)
+		}
+
+		if p, err := ParseSchedule(s.String()); err != nil || *p != *s {
+			t.Errorf("ParseSchedule(%q): Expected %v, actual %v (%v)",
+				s.String(), s, p, err)
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{"", "cosine:initial=1", "linear", "linear:initial",
+		"step:initial=x", "step:speed=1"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q): Expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package rl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"mnkagent/mnk"
+)
+
+// RewardShaping adds potential-based rewards for board features, giving
+// learning signal long before the first win. With the potential of terminal
+// states fixed at zero, the shaped rewards leave the optimal policy unchanged.
+type RewardShaping struct {
+	Threat float64 // Potential per open run of k-1 of the agent's marks
+	Block  float64 // Potential lost per open run of k-1 of the opponent's marks
+}
+
+// ParseShaping parses shaping terms such as "threat=0.1,block=0.1"
+func ParseShaping(spec string) (*RewardShaping, error) {
+	var r = new(RewardShaping)
+	for _, p := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok {
+			return nil, fmt.Errorf("shaping: invalid term %q", p)
+		}
+
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("shaping: invalid value for %s: %q", key, value)
+		}
+
+		switch key {
+		case "threat":
+			r.Threat = v
+		case "block":
+			r.Block = v
+		default:
+			return nil, fmt.Errorf("shaping: unknown term %q", key)
+		}
+	}
+	return r, nil
+}
+
+// potential returns the potential of state s for player, along with the
+// open runs of either side it was derived from
+func (r *RewardShaping) potential(s mnk.MNKState, m, n, k, player int) (phi float64, own, opponent int) {
+	own = openRuns(s, m, n, k, player)
+	opponent = openRuns(s, m, n, k, mnk.Opponent(player))
+	return r.Threat*float64(own) - r.Block*float64(opponent), own, opponent
+}
+
+// reward returns the shaping reward of moving from state s to next, the
+// discounted gain in potential, and a description of its terms
+func (r *RewardShaping) reward(s, next mnk.MNKState, terminal bool, m, n, k, player int, discount float64) (float64, string) {
+	phi, _, _ := r.potential(s, m, n, k, player)
+
+	var nextPhi float64
+	var own, opponent int
+	if !terminal {
+		nextPhi, own, opponent = r.potential(next, m, n, k, player)
+	}
+
+	f := discount*nextPhi - phi
+	return f, fmt.Sprintf("shaping %+.3f (open runs %d, opponent's %d)", f, own, opponent)
+}
+
+// openRuns counts the windows of k cells holding k-1 of player's marks and
+// an empty cell, i.e. the threats to win on the next move
+func openRuns(b mnk.MNKState, m, n, k, player int) (runs int) {
+	if k < 2 {
+		return
+	}
+
+	var directions = [4][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+	for i := 0; i < n; i++ {
+		for j := 0; j < m; j++ {
+			for _, d := range directions {
+				ei, ej := i+d[0]*(k-1), j+d[1]*(k-1)
+				if ei < 0 || ei >= n || ej < 0 || ej >= m {
+					continue
+				}
+
+				c := 0
+				for s := 0; s < k; s++ {
+					v := b[i+d[0]*s][j+d[1]*s]
+					if v == player {
+						c++
+					} else if v != 0 {
+						c = 0
+						break
+					}
+				}
+				if c == k-1 {
+					runs++
+				}
+			}
+		}
+	}
+	return
+}
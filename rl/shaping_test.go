@@ -0,0 +1,56 @@
+package rl
+
+import (
+	"strings"
+	"testing"
+
+	"mnkagent/mnk"
+)
+
+func TestOpenRuns(t *testing.T) {
+	var s = mnk.MNKState{{1, 1, 0}, {0, 2, 0}, {0, 0, 2}}
+
+	if r := openRuns(s, 3, 3, 3, 1); r != 1 {
+		t.Errorf("openRuns(): Expected 1 run of X, actual %d", r)
+	}
+	// The diagonal is blocked by X
+	if r := openRuns(s, 3, 3, 3, 2); r != 0 {
+		t.Errorf("openRuns(): Expected no runs of O, actual %d", r)
+	}
+}
+
+func TestRewardShaping(t *testing.T) {
+	var r = RewardShaping{Threat: 0.1, Block: 0.1}
+	var s = mnk.MNKState{{1, 0, 0}, {0, 0, 0}, {0, 0, 0}}
+	var next = mnk.MNKState{{1, 1, 0}, {0, 2, 0}, {0, 0, 0}}
+
+	// X went from no threats to one
+	f, terms := r.reward(s, next, false, 3, 3, 3, 1, 0.8)
+	if f < 0.079 || f > 0.081 {
+		t.Errorf("reward(): Expected 0.08, actual %g", f)
+	}
+	if !strings.Contains(terms, "open runs 1") {
+		t.Errorf("reward(): Expected the open runs in %q", terms)
+	}
+
+	// Terminal states have no potential
+	f, _ = r.reward(next, next, true, 3, 3, 3, 1, 0.8)
+	if f != -0.1 {
+		t.Errorf("reward(): Expected -0.1 at the end, actual %g", f)
+	}
+}
+
+func TestRLAgentShapingMessage(t *testing.T) {
+	var kw RLAgentKnowledge
+
+	agent := NewRLAgent(1, "X", 3, 3, 3, &kw, true)
+	agent.Shaping = &RewardShaping{Threat: 0.1}
+
+	var s = mnk.MNKState{{0, 0, 0}, {0, 0, 0}, {0, 0, 0}}
+	agent.FetchMove(s, []mnk.Action{mnk.MNKAction{}})
+	agent.FetchMove(mnk.MNKState{{1, 2, 0}, {0, 0, 0}, {0, 0, 0}}, []mnk.Action{mnk.MNKAction{}})
+
+	if msg := agent.FetchMessage(); !strings.Contains(msg, "shaping") {
+		t.Errorf("FetchMessage(): Expected the shaping terms, actual %q", msg)
+	}
+}
@@ -0,0 +1,523 @@
+package rl
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"sort"
+)
+
+// Size of a store record ahead of its key: table, value and statistics
+const storeRecordHeader = 1 + 8 + 3*8
+
+// valueStore is an append-only log of value records backing a knowledge
+// whose values do not all fit in memory. Cold values are evicted to the log
+// and read back on demand, and saving appends only the values that changed.
+// An index from the hash of each key to the offset of its latest record
+// keeps lookups to a single read.
+type valueStore struct {
+	path     string
+	file     *os.File
+	index    map[uint64]int64
+	end      int64
+	records  int
+	dirty    map[storeKey]bool
+	capacity int  // Values kept in memory, unbounded if zero
+	rewrite  bool // Rewrite the log from memory on the next flush
+}
+
+type storeKey struct {
+	table rlTable
+	key   string
+}
+
+// storeRecord is a single entry of the log
+type storeRecord struct {
+	table rlTable
+	key   string
+	value float64
+	stats RLStats
+}
+
+// openValueStore opens the log at path, creating it if needed, and indexes
+// its records
+func openValueStore(path string, capacity int) (*valueStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &valueStore{
+		path:     path,
+		file:     file,
+		dirty:    make(map[storeKey]bool),
+		capacity: capacity,
+	}
+	if err = s.scan(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// scan rebuilds the index; later records of a key replace earlier ones
+func (s *valueStore) scan() error {
+	s.index = make(map[uint64]int64)
+	s.end, s.records = 0, 0
+
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(s.file)
+	for {
+		rec, size, err := readStoreRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// A record cut short by a crash; it is overwritten by the next one
+			return s.file.Truncate(s.end)
+		}
+		if err != nil {
+			return err
+		}
+
+		s.index[storeHash(rec.table, rec.key)] = s.end
+		s.end += size
+		s.records++
+	}
+}
+
+// read returns the latest record of the key in the given table
+func (s *valueStore) read(table rlTable, key string) (storeRecord, bool) {
+	off, ok := s.index[storeHash(table, key)]
+	if !ok {
+		return storeRecord{}, false
+	}
+
+	rec, _, err := readStoreRecord(io.NewSectionReader(s.file, off, s.end-off))
+	if err != nil || rec.table != table || rec.key != key {
+		return storeRecord{}, false // Hash collisions count as misses
+	}
+	return rec, true
+}
+
+// append writes records to the end of the log and indexes them
+func (s *valueStore) append(records []storeRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	w := bufio.NewWriter(io.NewOffsetWriter(s.file, s.end))
+	off := s.end
+	for _, rec := range records {
+		size, err := writeStoreRecord(w, rec)
+		if err != nil {
+			return err
+		}
+		s.index[storeHash(rec.table, rec.key)] = off
+		off += size
+		s.records++
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	s.end = off
+	return nil
+}
+
+// flush writes the changed values of the knowledge to the log, compacting
+// it once most of its records are stale; the knowledge must be locked
+func (s *valueStore) flush(k *RLAgentKnowledge) error {
+	if s.rewrite {
+		return s.compact(k, true)
+	}
+
+	var records []storeRecord
+	for sk := range s.dirty {
+		if rec, ok := k.record(sk.table, sk.key); ok {
+			records = append(records, rec)
+		}
+	}
+	if err := s.append(records); err != nil {
+		return err
+	}
+	clear(s.dirty)
+
+	if s.records > 1024 && s.records > 2*len(s.index) {
+		return s.compact(k, false)
+	}
+	return nil
+}
+
+// compact rewrites the log with only the latest record of each key, taken
+// from memory alone if all values are resident
+func (s *valueStore) compact(k *RLAgentKnowledge, resident bool) error {
+	tmp, err := os.Create(s.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if resident {
+		for _, table := range []rlTable{rlTableA, rlTableB} {
+			for key := range k.table(table) {
+				rec, _ := k.record(table, key)
+				if _, err = writeStoreRecord(w, rec); err != nil {
+					tmp.Close()
+					return err
+				}
+			}
+		}
+	} else {
+		for _, off := range s.index {
+			rec, _, err := readStoreRecord(io.NewSectionReader(s.file, off, s.end-off))
+			if err == nil {
+				_, err = writeStoreRecord(w, rec)
+			}
+			if err != nil {
+				tmp.Close()
+				return err
+			}
+		}
+	}
+	if err = w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	s.file.Close()
+	if err = os.Rename(tmp.Name(), s.path); err != nil {
+		tmp.Close()
+		return err
+	}
+	s.file = tmp
+	s.rewrite = false
+	clear(s.dirty)
+	return s.scan()
+}
+
+// each calls fn with the latest record of every key
+func (s *valueStore) each(fn func(storeRecord)) error {
+	for _, off := range s.index {
+		rec, _, err := readStoreRecord(io.NewSectionReader(s.file, off, s.end-off))
+		if err != nil {
+			return err
+		}
+		fn(rec)
+	}
+	return nil
+}
+
+// owns reports whether the log is the one of the model file at path
+func (s *valueStore) owns(path string) bool {
+	a, err := os.Stat(path + ".values")
+	if err != nil {
+		return false
+	}
+	b, err := s.file.Stat()
+	return err == nil && os.SameFile(a, b)
+}
+
+// close releases the log
+func (s *valueStore) close() error {
+	return s.file.Close()
+}
+
+func storeHash(table rlTable, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte{byte(table)})
+	io.WriteString(h, key)
+	return h.Sum64()
+}
+
+func writeStoreRecord(w io.Writer, rec storeRecord) (int64, error) {
+	buf := make([]byte, 4+storeRecordHeader+len(rec.key))
+	binary.LittleEndian.PutUint32(buf, uint32(storeRecordHeader+len(rec.key)))
+	buf[4] = byte(rec.table)
+	binary.LittleEndian.PutUint64(buf[5:], math.Float64bits(rec.value))
+	binary.LittleEndian.PutUint64(buf[13:], uint64(rec.stats.Visits))
+	binary.LittleEndian.PutUint64(buf[21:], uint64(rec.stats.Updates))
+	binary.LittleEndian.PutUint64(buf[29:], uint64(rec.stats.LastUpdated))
+	copy(buf[4+storeRecordHeader:], rec.key)
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+func readStoreRecord(r io.Reader) (rec storeRecord, size int64, err error) {
+	var length [4]byte
+	if _, err = io.ReadFull(r, length[:]); err != nil {
+		return
+	}
+
+	n := binary.LittleEndian.Uint32(length[:])
+	if n < storeRecordHeader {
+		return rec, 0, fmt.Errorf("store: invalid record length %d", n)
+	}
+	buf := make([]byte, n)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+
+	rec.table = rlTable(buf[0])
+	rec.value = math.Float64frombits(binary.LittleEndian.Uint64(buf[1:]))
+	rec.stats.Visits = uint(binary.LittleEndian.Uint64(buf[9:]))
+	rec.stats.Updates = uint(binary.LittleEndian.Uint64(buf[17:]))
+	rec.stats.LastUpdated = uint(binary.LittleEndian.Uint64(buf[25:]))
+	rec.key = string(buf[storeRecordHeader:])
+	return rec, int64(4 + n), nil
+}
+
+// table returns the values of the given table
+func (k *RLAgentKnowledge) table(table rlTable) map[string]float64 {
+	if table == rlTableB {
+		return k.ValuesB
+	}
+	return k.Values
+}
+
+// record returns the resident value of the key as a store record
+func (k *RLAgentKnowledge) record(table rlTable, key string) (storeRecord, bool) {
+	v, ok := k.table(table)[key]
+	if !ok {
+		return storeRecord{}, false
+	}
+
+	rec := storeRecord{table: table, key: key, value: v}
+	if table == rlTableA {
+		rec.stats = k.Stats[key]
+	}
+	return rec, true
+}
+
+// touch marks the key as changed since the last save; the knowledge must be
+// locked
+func (k *RLAgentKnowledge) touch(table rlTable, key string) {
+	if k.store != nil {
+		k.store.dirty[storeKey{table, key}] = true
+	}
+}
+
+// fault reads an evicted value back from the store; the knowledge must be
+// locked
+func (k *RLAgentKnowledge) fault(table rlTable, key string) (float64, bool) {
+	if k.store == nil {
+		return 0, false
+	}
+
+	rec, ok := k.store.read(table, key)
+	if !ok {
+		return 0, false
+	}
+
+	if table == rlTableA {
+		// Statistics gathered since the eviction add up
+		stats := k.Stats[key]
+		stats.Visits += rec.stats.Visits
+		stats.Updates += rec.stats.Updates
+		if rec.stats.LastUpdated > stats.LastUpdated {
+			stats.LastUpdated = rec.stats.LastUpdated
+		}
+		if stats != (RLStats{}) {
+			k.Stats[key] = stats
+		}
+	}
+	return rec.value, true
+}
+
+// insert makes a value resident, evicting cold values from a full memory;
+// the knowledge must be locked
+func (k *RLAgentKnowledge) insert(table rlTable, key string, v float64) {
+	if k.store != nil && k.store.capacity > 0 && table == rlTableA &&
+		len(k.Values) >= k.store.capacity {
+		if err := k.evict(); err != nil {
+			fmt.Println("[error] Could not evict values to the store!")
+			fmt.Println(err)
+		}
+	}
+	k.table(table)[key] = v
+}
+
+// evict moves the tenth of the resident values updated longest ago to the
+// store; the knowledge must be locked
+func (k *RLAgentKnowledge) evict() error {
+	var keys = make([]string, 0, len(k.Values))
+	for key := range k.Values {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return k.Stats[keys[i]].LastUpdated < k.Stats[keys[j]].LastUpdated
+	})
+	if batch := k.store.capacity / 10; batch > 0 && batch < len(keys) {
+		keys = keys[:batch]
+	} else if len(keys) > 1 {
+		keys = keys[:1]
+	}
+
+	// Unchanged values are in the store already, or are defaults
+	var records []storeRecord
+	for _, key := range keys {
+		for _, table := range []rlTable{rlTableA, rlTableB} {
+			if k.store.dirty[storeKey{table, key}] {
+				rec, _ := k.record(table, key)
+				records = append(records, rec)
+			}
+		}
+	}
+	if err := k.store.append(records); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		delete(k.Values, key)
+		delete(k.ValuesB, key)
+		delete(k.Stats, key)
+		delete(k.store.dirty, storeKey{rlTableA, key})
+		delete(k.store.dirty, storeKey{rlTableB, key})
+	}
+	return nil
+}
+
+// OpenStore backs the knowledge with the log at path, keeping at most
+// capacity values in memory
+func (k *RLAgentKnowledge) OpenStore(path string, capacity int) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.store != nil {
+		k.store.close()
+	}
+
+	store, err := openValueStore(path, capacity)
+	if err != nil {
+		return err
+	}
+	k.store = store
+	k.Store = "log"
+
+	// Values of a model moving to the store are written on the next save
+	if store.records == 0 {
+		for _, table := range []rlTable{rlTableA, rlTableB} {
+			for key := range k.table(table) {
+				k.touch(table, key)
+			}
+		}
+	}
+	return nil
+}
+
+// CloseStore reads all values into memory and detaches the store, so that
+// the whole model is saved in its own file again
+func (k *RLAgentKnowledge) CloseStore() error {
+	if err := k.LoadAll(); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.store == nil {
+		return nil
+	}
+	k.store.close()
+	k.store = nil
+	k.Store = ""
+	return nil
+}
+
+// SetMemoryCap limits the values of a knowledge backed by a store kept in
+// memory; the least recently updated spill to the store
+func (k *RLAgentKnowledge) SetMemoryCap(capacity int) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.store == nil {
+		return errors.New("a memory cap needs the log value store")
+	}
+	k.store.capacity = capacity
+	return nil
+}
+
+// LoadAll reads every value of the store into memory, as needed by commands
+// operating on the whole model
+func (k *RLAgentKnowledge) LoadAll() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.store == nil {
+		return nil
+	}
+
+	if k.Values == nil {
+		k.Values = make(map[string]float64)
+	}
+	if k.Stats == nil {
+		k.Stats = make(map[string]RLStats)
+	}
+	err := k.store.each(func(rec storeRecord) {
+		if rec.table == rlTableB && k.ValuesB == nil {
+			k.ValuesB = make(map[string]float64)
+		}
+		addRecord(k.table(rec.table), k.Stats, rec)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Commands may change or drop any value
+	k.store.capacity = 0
+	k.store.rewrite = true
+	return nil
+}
+
+// merged returns copies of the values and statistics of the knowledge along
+// with those left in the store; the knowledge must be locked
+func (k *RLAgentKnowledge) merged() (values, valuesB map[string]float64, stats map[string]RLStats, err error) {
+	values, valuesB, stats = maps.Clone(k.Values), maps.Clone(k.ValuesB), maps.Clone(k.Stats)
+	if values == nil {
+		values = make(map[string]float64)
+	}
+	if stats == nil {
+		stats = make(map[string]RLStats)
+	}
+
+	// Once all values were loaded, dropped ones stay dropped
+	if k.store.rewrite {
+		return
+	}
+
+	err = k.store.each(func(rec storeRecord) {
+		if rec.table == rlTableB {
+			if valuesB == nil {
+				valuesB = make(map[string]float64)
+			}
+			addRecord(valuesB, stats, rec)
+		} else {
+			addRecord(values, stats, rec)
+		}
+	})
+	return
+}
+
+// addRecord adds a record of the store to the given values and statistics,
+// unless the value is resident and thus newer
+func addRecord(values map[string]float64, stats map[string]RLStats, rec storeRecord) {
+	if _, ok := values[rec.key]; ok {
+		return
+	}
+	values[rec.key] = rec.value
+	if rec.table == rlTableA && rec.stats != (RLStats{}) {
+		stats[rec.key] = rec.stats
+	}
+}
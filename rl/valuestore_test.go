@@ -0,0 +1,145 @@
+package rl
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestValueStoreEviction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model")
+	kw := new(RLAgentKnowledge)
+	kw.init(3, 3)
+	if err := kw.OpenStore(path+".values", 10); err != nil {
+		t.Fatalf("OpenStore(): Unexpected error %v", err)
+	}
+
+	kw.mu.Lock()
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprint("s", i)
+		kw.Iterations = uint(i)
+		kw.insert(rlTableA, key, float64(i))
+		kw.updated(key)
+		kw.touch(rlTableA, key)
+	}
+	if len(kw.Values) > 10 {
+		t.Errorf("insert(): Expected at most 10 values in memory, actual %d", len(kw.Values))
+	}
+	if _, ok := kw.Values["s0"]; ok {
+		t.Error("insert(): Expected the oldest value to be evicted")
+	}
+	if v, ok := kw.fault(rlTableA, "s0"); !ok || v != 0 || kw.Stats["s0"].Updates != 1 {
+		t.Errorf("fault(): Expected s0 = 0 with 1 update, actual %g (%t) %+v", v, ok, kw.Stats["s0"])
+	}
+	kw.mu.Unlock()
+
+	if !kw.SaveToFile(path) {
+		t.Fatal("SaveToFile(): Expected the model to be saved")
+	}
+
+	loaded := new(RLAgentKnowledge)
+	if !loaded.LoadFromFile(path) {
+		t.Fatal("LoadFromFile(): Expected the model to load")
+	}
+	if loaded.Store != "log" || len(loaded.Values) != 0 {
+		t.Errorf("LoadFromFile(): Expected values left in the store, actual %d in memory", len(loaded.Values))
+	}
+	if err := loaded.LoadAll(); err != nil {
+		t.Fatalf("LoadAll(): Unexpected error %v", err)
+	}
+	if len(loaded.Values) != 30 || loaded.Values["s29"] != 29 || loaded.Stats["s29"].LastUpdated != 29 {
+		t.Errorf("LoadAll(): Expected all 30 values and their statistics, actual %d and %+v",
+			len(loaded.Values), loaded.Stats["s29"])
+	}
+}
+
+func TestValueStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.values")
+	kw := new(RLAgentKnowledge)
+	kw.init(3, 3)
+	if err := kw.OpenStore(path, 0); err != nil {
+		t.Fatalf("OpenStore(): Unexpected error %v", err)
+	}
+
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 500; i++ {
+			key := fmt.Sprint("s", i)
+			kw.Values[key] = float64(round)
+			kw.touch(rlTableA, key)
+		}
+		if err := kw.store.flush(kw); err != nil {
+			t.Fatalf("flush(): Unexpected error %v", err)
+		}
+	}
+
+	if kw.store.records != 500 {
+		t.Errorf("flush(): Expected the log compacted to 500 records, actual %d", kw.store.records)
+	}
+	if rec, ok := kw.store.read(rlTableA, "s42"); !ok || rec.value != 4 {
+		t.Errorf("read(): Expected the latest value 4, actual %g (%t)", rec.value, ok)
+	}
+}
+
+func TestValueStoreSaveElsewhere(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "model")
+	kw := new(RLAgentKnowledge)
+	kw.init(3, 3)
+	if err := kw.OpenStore(path+".values", 10); err != nil {
+		t.Fatalf("OpenStore(): Unexpected error %v", err)
+	}
+
+	kw.mu.Lock()
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprint("s", i)
+		kw.insert(rlTableA, key, float64(i))
+		kw.Stats[key] = RLStats{Visits: uint(i)}
+		kw.touch(rlTableA, key)
+	}
+	kw.mu.Unlock()
+	if !kw.SaveToFile(path) {
+		t.Fatal("SaveToFile(): Expected the model to be saved")
+	}
+
+	// Exporting a copy keeps the evicted values
+	if !kw.SaveToFile(filepath.Join(dir, "copy")) {
+		t.Fatal("SaveToFile(): Expected the copy to be saved")
+	}
+	var copied RLAgentKnowledge
+	if !copied.LoadFromFile(filepath.Join(dir, "copy")) {
+		t.Fatal("LoadFromFile(): Expected the copy to load")
+	}
+	if copied.Store != "" || len(copied.Values) != 30 || copied.Stats["s1"].Visits != 1 {
+		t.Errorf("SaveToFile(): Expected a self-contained copy of 30 values, actual %q and %d",
+			copied.Store, len(copied.Values))
+	}
+
+	// Pruning into another file leaves the model alone
+	loaded := new(RLAgentKnowledge)
+	if !loaded.LoadFromFile(path) {
+		t.Fatal("LoadFromFile(): Expected the model to load")
+	}
+	if err := loaded.LoadAll(); err != nil {
+		t.Fatalf("LoadAll(): Unexpected error %v", err)
+	}
+	if pruned := PruneModel(loaded, 20, 0); pruned != 20 {
+		t.Errorf("PruneModel(): Expected 20 states dropped, actual %d", pruned)
+	}
+	if !loaded.SaveToFile(filepath.Join(dir, "pruned")) {
+		t.Fatal("SaveToFile(): Expected the pruned model to be saved")
+	}
+
+	var pruned, source RLAgentKnowledge
+	if !pruned.LoadFromFile(filepath.Join(dir, "pruned")) || len(pruned.Values) != 10 {
+		t.Errorf("LoadFromFile(): Expected the 10 pruned values, actual %d", len(pruned.Values))
+	}
+	if !source.LoadFromFile(path) {
+		t.Fatal("LoadFromFile(): Expected the model to load")
+	}
+	if err := source.LoadAll(); err != nil || len(source.Values) != 30 {
+		t.Errorf("LoadAll(): Expected the model to keep its 30 values, actual %d (%v)",
+			len(source.Values), err)
+	}
+}
@@ -0,0 +1,60 @@
+package tournament
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Elo rating parameters
+const (
+	Initial = 1500
+	K       = 32
+)
+
+// Rating is a single entry of the persisted rating table
+type Rating struct {
+	Rating float64 `json:"rating"`
+	Games  int     `json:"games"`
+}
+
+// Ratings maps agent specs to their ratings
+type Ratings map[string]*Rating
+
+// UpdateElo adjusts both ratings after a game where a scored score
+func UpdateElo(a, b *Rating, score float64) {
+	expected := 1 / (1 + math.Pow(10, (b.Rating-a.Rating)/400))
+	delta := K * (score - expected)
+
+	a.Rating += delta
+	b.Rating -= delta
+	a.Games++
+	b.Games++
+}
+
+// LoadRatings reads the rating table, starting afresh if it does not exist
+func LoadRatings(path string) (Ratings, error) {
+	ratings := make(Ratings)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ratings, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, &ratings); err != nil {
+		return nil, fmt.Errorf("tournament: invalid ratings file: %w", err)
+	}
+	return ratings, nil
+}
+
+// SaveToFile writes the rating table to disk
+func (r Ratings) SaveToFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
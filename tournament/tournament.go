@@ -0,0 +1,280 @@
+// Package tournament plays agents against each other in round-robin and
+// swiss tournaments and keeps their Elo ratings
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"mnkagent/game"
+	"mnkagent/mnk"
+)
+
+// Entry tracks a participant's results
+type Entry struct {
+	Spec    string
+	Score   float64
+	Results map[int]*[3]int // Opponent index to wins/losses/draws
+	Bye     bool
+}
+
+// Tournament plays the agents of its entries against each other on a single
+// environment
+type Tournament struct {
+	env      mnk.Environment
+	entries  []*Entry
+	ratings  Ratings
+	newAgent func(spec string, id int) (mnk.Agent, error)
+
+	// Games per pairing, alternating colors
+	Games int
+
+	// Observer follows every game, and Seated names its agents beforehand,
+	// e.g. for a game.Recorder; either may be nil
+	Observer game.Observer
+	Seated   func(x, o string)
+
+	// Out receives the progress of the tournament, if set
+	Out io.Writer
+}
+
+// New creates a tournament of the agents of the given specs, constructed by
+// newAgent, rated by ratings. Learning agents are frozen, since ratings are
+// only meaningful for agents that do not change meanwhile.
+func New(env mnk.Environment, specs []string, ratings Ratings,
+	newAgent func(spec string, id int) (mnk.Agent, error)) (*Tournament, error) {
+	if len(specs) < 2 {
+		return nil, errors.New("tournament: at least two agents are required")
+	}
+
+	t := &Tournament{env: env, ratings: ratings, newAgent: newAgent, Games: 1}
+	for i, spec := range specs {
+		// Ratings are kept by spec
+		for _, e := range t.entries[:i] {
+			if e.Spec == spec {
+				return nil, fmt.Errorf("tournament: agent %q entered twice", spec)
+			}
+		}
+
+		// Fail early on invalid specs
+		if _, err := newAgent(spec, 1); err != nil {
+			return nil, err
+		}
+		t.entries = append(t.entries, &Entry{Spec: spec, Results: make(map[int]*[3]int)})
+	}
+
+	// The ratings are only touched once every entrant is valid
+	for _, e := range t.entries {
+		if ratings[e.Spec] == nil {
+			ratings[e.Spec] = &Rating{Rating: Initial}
+		}
+	}
+	return t, nil
+}
+
+// Entries returns the participants in the order of their specs
+func (t *Tournament) Entries() []*Entry {
+	return t.entries
+}
+
+// RoundRobin plays every pairing of the entries once
+func (t *Tournament) RoundRobin() error {
+	for i := range t.entries {
+		for j := i + 1; j < len(t.entries); j++ {
+			if err := t.match(i, j); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Swiss plays rounds of pairings of entries of similar scores; zero rounds
+// play log2 of the number of entries plus one
+func (t *Tournament) Swiss(rounds int) error {
+	if rounds <= 0 {
+		rounds = int(math.Ceil(math.Log2(float64(len(t.entries))))) + 1
+	}
+	for r := 1; r <= rounds; r++ {
+		t.printf("Round %d/%d\n", r, rounds)
+		for _, pair := range SwissPairs(t.entries, t.ratings) {
+			if pair[1] < 0 {
+				t.entries[pair[0]].Score += float64(t.Games)
+				t.entries[pair[0]].Bye = true
+				continue
+			}
+			if err := t.match(pair[0], pair[1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// match plays games between two entries, alternating who plays X
+func (t *Tournament) match(i, j int) error {
+	a, b := t.entries[i], t.entries[j]
+	t.printf("%s vs %s\n", a.Spec, b.Spec)
+
+	if a.Results[j] == nil {
+		a.Results[j] = new([3]int)
+		b.Results[i] = new([3]int)
+	}
+
+	// Both colour assignments of the pairing
+	var seats [2][3]mnk.Agent
+	var specs = [2][3]string{{"", a.Spec, b.Spec}, {"", b.Spec, a.Spec}}
+	for c := range seats {
+		for id := 1; id <= 2; id++ {
+			agent, err := t.newAgent(specs[c][id], id)
+			if err != nil {
+				return err
+			}
+
+			// Learning agents play greedily by their current model
+			if l, ok := agent.(interface{ Freeze() }); ok {
+				l.Freeze()
+			}
+			seats[c][id] = agent
+		}
+	}
+
+	for g := 0; g < t.Games; g++ {
+		c := g % 2
+		if t.Seated != nil {
+			t.Seated(specs[c][1], specs[c][2])
+		}
+
+		winner, err := game.Round(t.env, seats[c], 1, t.Observer)
+		if err != nil {
+			return err
+		}
+
+		// Score from a's point of view
+		var score float64 = 0.5
+		switch {
+		case winner == 0:
+			a.Results[j][2]++
+			b.Results[i][2]++
+		case (winner == 1) == (c == 0):
+			score = 1
+			a.Results[j][0]++
+			b.Results[i][1]++
+		default:
+			score = 0
+			a.Results[j][1]++
+			b.Results[i][0]++
+		}
+
+		a.Score += score
+		b.Score += 1 - score
+		UpdateElo(t.ratings[a.Spec], t.ratings[b.Spec], score)
+	}
+
+	return nil
+}
+
+func (t *Tournament) printf(format string, args ...interface{}) {
+	if t.Out != nil {
+		fmt.Fprintf(t.Out, format, args...)
+	}
+}
+
+// SwissPairs pairs entries of similar scores that have not met yet. An entry
+// left without an opponent is paired with -1 for a bye.
+func SwissPairs(entries []*Entry, ratings Ratings) (pairs [][2]int) {
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(x, y int) bool {
+		a, b := entries[order[x]], entries[order[y]]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return ratings[a.Spec].Rating > ratings[b.Spec].Rating
+	})
+
+	// The lowest ranked entry that has not had a bye sits out
+	if len(order)%2 == 1 {
+		sitOut := len(order) - 1
+		for i := len(order) - 1; i >= 0; i-- {
+			if !entries[order[i]].Bye {
+				sitOut = i
+				break
+			}
+		}
+		pairs = append(pairs, [2]int{order[sitOut], -1})
+		order = append(order[:sitOut], order[sitOut+1:]...)
+	}
+
+	paired := make([]bool, len(order))
+	for x := range order {
+		if paired[x] {
+			continue
+		}
+
+		// Prefer the closest opponent not met yet, else the closest one
+		opponent := -1
+		for y := x + 1; y < len(order); y++ {
+			if paired[y] {
+				continue
+			}
+			if opponent < 0 {
+				opponent = y
+			}
+			if entries[order[x]].Results[order[y]] == nil {
+				opponent = y
+				break
+			}
+		}
+
+		paired[x], paired[opponent] = true, true
+		pairs = append(pairs, [2]int{order[x], order[opponent]})
+	}
+	return
+}
+
+// PrintCrosstable writes the results of every pairing and the ratings to w
+func (t *Tournament) PrintCrosstable(w io.Writer) {
+	entries := t.entries
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(x, y int) bool {
+		return entries[order[x]].Score > entries[order[y]].Score
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := []string{"#", "Agent", "Elo", "Score"}
+	for i := range order {
+		header = append(header, fmt.Sprint(i+1))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t")+"\t")
+
+	for r, i := range order {
+		e := entries[i]
+		row := []string{fmt.Sprint(r + 1), e.Spec,
+			fmt.Sprintf("%.0f", t.ratings[e.Spec].Rating), fmt.Sprintf("%g", e.Score)}
+
+		for _, j := range order {
+			switch res := e.Results[j]; {
+			case i == j:
+				row = append(row, "x")
+			case res == nil:
+				row = append(row, "-")
+			default:
+				row = append(row, fmt.Sprintf("%d/%d/%d", res[0], res[1], res[2]))
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t")+"\t")
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "Cells read wins/losses/draws of the row agent against the column agent")
+}
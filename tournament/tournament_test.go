@@ -0,0 +1,124 @@
+package tournament
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"mnkagent/agent"
+	"mnkagent/mnk"
+)
+
+func TestUpdateElo(t *testing.T) {
+	a, b := &Rating{Rating: 1500}, &Rating{Rating: 1500}
+	UpdateElo(a, b, 1)
+	if a.Rating != 1516 || b.Rating != 1484 || a.Games != 1 || b.Games != 1 {
+		t.Errorf("UpdateElo(): Expected 1516 and 1484 after one game, actual %+v %+v", a, b)
+	}
+
+	// A draw against a weaker opponent costs rating
+	a, b = &Rating{Rating: 1900}, &Rating{Rating: 1500}
+	UpdateElo(a, b, 0.5)
+	if want := 1900 + K*(0.5-10.0/11); math.Abs(a.Rating-want) > 1e-9 {
+		t.Errorf("UpdateElo(): Expected the favourite to drop to %f on a draw, actual %f", want, a.Rating)
+	}
+	if math.Abs(a.Rating+b.Rating-3400) > 1e-9 {
+		t.Errorf("UpdateElo(): Expected the ratings to keep their sum, actual %f", a.Rating+b.Rating)
+	}
+}
+
+func TestSwissPairs(t *testing.T) {
+	newEntries := func(scores ...float64) ([]*Entry, Ratings) {
+		var entries []*Entry
+		ratings := make(Ratings)
+		for i, s := range scores {
+			spec := string(rune('a' + i))
+			entries = append(entries, &Entry{Spec: spec, Score: s,
+				Results: make(map[int]*[3]int)})
+			ratings[spec] = &Rating{Rating: Initial}
+		}
+		return entries, ratings
+	}
+
+	// Entries of similar scores meet
+	entries, ratings := newEntries(0, 3, 1, 2)
+	pairs := SwissPairs(entries, ratings)
+	if len(pairs) != 2 || pairs[0] != [2]int{1, 3} || pairs[1] != [2]int{2, 0} {
+		t.Errorf("SwissPairs(): Expected [1 3] [2 0], actual %v", pairs)
+	}
+
+	// Rematches are avoided
+	entries[1].Results[3], entries[3].Results[1] = new([3]int), new([3]int)
+	pairs = SwissPairs(entries, ratings)
+	if len(pairs) != 2 || pairs[0] != [2]int{1, 2} || pairs[1] != [2]int{3, 0} {
+		t.Errorf("SwissPairs(): Expected [1 2] [3 0], actual %v", pairs)
+	}
+
+	// The lowest ranked entry without a bye sits out
+	entries, ratings = newEntries(2, 1, 0)
+	entries[2].Bye = true
+	pairs = SwissPairs(entries, ratings)
+	if len(pairs) != 2 || pairs[0] != [2]int{1, -1} || pairs[1] != [2]int{0, 2} {
+		t.Errorf("SwissPairs(): Expected [1 -1] [0 2], actual %v", pairs)
+	}
+}
+
+// freezable is an agent that reports whether it was frozen
+type freezable struct {
+	mnk.Agent
+	frozen bool
+}
+
+func (f *freezable) Freeze() { f.frozen = true }
+
+func TestRoundRobin(t *testing.T) {
+	env, _ := mnk.NewMNKBoard(3, 3, 3)
+	var agents []*freezable
+	newAgent := func(spec string, id int) (mnk.Agent, error) {
+		a := &freezable{Agent: agent.NewRandomAgent(id, spec)}
+		agents = append(agents, a)
+		return a, nil
+	}
+
+	ratings := make(Ratings)
+	if _, err := New(env, []string{"a", "b", "a"}, ratings, newAgent); err == nil {
+		t.Error("New(): Expected an error for an agent entered twice")
+	}
+	if len(ratings) != 0 {
+		t.Errorf("New(): Expected the ratings to be left alone, actual %d", len(ratings))
+	}
+
+	tm, err := New(env, []string{"a", "b", "c"}, ratings, newAgent)
+	if err != nil {
+		t.Fatalf("New(): Unexpected error %v", err)
+	}
+	tm.Games = 4
+	var seated int
+	tm.Seated = func(x, o string) { seated++ }
+	if err = tm.RoundRobin(); err != nil {
+		t.Fatalf("RoundRobin(): Unexpected error %v", err)
+	}
+
+	var score float64
+	for _, e := range tm.Entries() {
+		score += e.Score
+	}
+	if score != 12 || seated != 12 || ratings["a"].Games != 8 {
+		t.Errorf("RoundRobin(): Expected 12 games of 8 per agent, actual %g points, %d games and %+v",
+			score, seated, ratings["a"])
+	}
+	// Past the agents created to validate the specs
+	for _, a := range agents[5:] {
+		if !a.frozen {
+			t.Fatal("RoundRobin(): Expected every agent to be frozen")
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "ratings.json")
+	if err = ratings.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile(): Unexpected error %v", err)
+	}
+	if loaded, err := LoadRatings(path); err != nil || *loaded["b"] != *ratings["b"] {
+		t.Errorf("LoadRatings(): Expected the saved ratings, actual %v (%v)", loaded, err)
+	}
+}
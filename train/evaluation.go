@@ -0,0 +1,111 @@
+package train
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"mnkagent/game"
+	"mnkagent/mnk"
+)
+
+// Evaluation summarizes greedy games of the model against a baseline
+type Evaluation struct {
+	Games, Wins, Draws, Losses int
+}
+
+// WinRate returns the share of games won
+func (r Evaluation) WinRate() float64 {
+	if r.Games == 0 {
+		return 0
+	}
+	return float64(r.Wins) / float64(r.Games)
+}
+
+// Evaluate plays games on env between the agents of learner, which should
+// neither learn nor explore, and of baseline, alternating colors
+func Evaluate(env mnk.Environment, learner, baseline Factory, games int) (r Evaluation, err error) {
+	var seats [2][3]mnk.Agent
+	for c := range seats {
+		for id := 1; id <= 2; id++ {
+			create := baseline
+			if id == 1+c {
+				create = learner
+			}
+			if seats[c][id], err = create(id); err != nil {
+				return
+			}
+		}
+	}
+
+	for g := 0; g < games; g++ {
+		c := g % 2
+
+		var winner int
+		if winner, err = game.Round(env, seats[c], 1, nil); err != nil {
+			return
+		}
+
+		r.Games++
+		switch winner {
+		case 0:
+			r.Draws++
+		case 1 + c:
+			r.Wins++
+		default:
+			r.Losses++
+		}
+	}
+	return
+}
+
+// LogEvaluation appends an evaluation result at the given iteration of the
+// run and of the model to the CSV file at path
+func LogEvaluation(path string, iteration, modelIterations uint, r Evaluation) error {
+	_, statErr := os.Stat(path)
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if os.IsNotExist(statErr) {
+		w.Write([]string{"iteration", "model_iterations", "games", "wins",
+			"draws", "losses", "win_rate"})
+	}
+	w.Write([]string{
+		strconv.FormatUint(uint64(iteration), 10),
+		strconv.FormatUint(uint64(modelIterations), 10),
+		strconv.Itoa(r.Games),
+		strconv.Itoa(r.Wins),
+		strconv.Itoa(r.Draws),
+		strconv.Itoa(r.Losses),
+		fmt.Sprintf("%.4f", r.WinRate()),
+	})
+	w.Flush()
+	return w.Error()
+}
+
+// ReadEvaluation returns the last result of an evaluation log
+func ReadEvaluation(r io.Reader) (result Evaluation, err error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return
+	}
+	if len(rows) < 2 || len(rows[len(rows)-1]) < 6 {
+		return result, errors.New("empty evaluation log")
+	}
+
+	row := rows[len(rows)-1]
+	for i, v := range []*int{&result.Games, &result.Wins, &result.Draws, &result.Losses} {
+		if *v, err = strconv.Atoi(row[2+i]); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,34 @@
+package train
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mnkagent/agent"
+	"mnkagent/mnk"
+)
+
+func TestEvaluate(t *testing.T) {
+	env, _ := mnk.NewMNKBoard(3, 3, 3)
+	minimax := func(id int) (mnk.Agent, error) { return agent.NewMinimaxAgent(id, "", 3, 3, 3, 9), nil }
+	random := func(id int) (mnk.Agent, error) { return agent.NewRandomAgent(id, ""), nil }
+
+	// Perfect play never loses
+	r, err := Evaluate(env, minimax, random, 10)
+	if err != nil || r.Games != 10 || r.Losses != 0 || r.Wins == 0 {
+		t.Errorf("Evaluate(): Expected 10 games without losses, actual %+v (%v)", r, err)
+	}
+	if r.WinRate() != float64(r.Wins)/10 {
+		t.Errorf("WinRate(): Expected %g, actual %g", float64(r.Wins)/10, r.WinRate())
+	}
+
+	path := filepath.Join(t.TempDir(), "eval.csv")
+	LogEvaluation(path, 100, 200, Evaluation{Games: 10, Wins: 5, Draws: 1, Losses: 4})
+	LogEvaluation(path, 200, 400, Evaluation{Games: 10, Wins: 7, Draws: 1, Losses: 2})
+	file, _ := os.Open(path)
+	defer file.Close()
+	if r, err = ReadEvaluation(file); err != nil || r != (Evaluation{Games: 10, Wins: 7, Draws: 1, Losses: 2}) {
+		t.Errorf("ReadEvaluation(): Expected the last result, actual %+v (%v)", r, err)
+	}
+}
@@ -0,0 +1,195 @@
+package train
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"mnkagent/mnk"
+)
+
+// Weights are the relative odds of the kinds of league opponents
+type Weights struct {
+	Self     float64
+	Past     float64
+	Baseline float64
+}
+
+// ParseWeights parses weights such as "self=0.5,past=0.3,baseline=0.2"
+func ParseWeights(spec string) (w Weights, err error) {
+	for _, p := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok {
+			return w, fmt.Errorf("league: invalid weight %q", p)
+		}
+
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 {
+			return w, fmt.Errorf("league: invalid value for %s: %q", key, value)
+		}
+
+		switch key {
+		case "self":
+			w.Self = v
+		case "past":
+			w.Past = v
+		case "baseline":
+			w.Baseline = v
+		default:
+			return w, fmt.Errorf("league: unknown opponent kind %q", key)
+		}
+	}
+
+	if w.Self+w.Past+w.Baseline == 0 {
+		return w, fmt.Errorf("league: weights %q are all zero", spec)
+	}
+	return w, nil
+}
+
+// Snapshot is a frozen copy of the model at some iteration, playing as the
+// opponents it creates
+type Snapshot struct {
+	Iteration uint
+	Opponent  Factory
+}
+
+// baseline is a scripted opponent of the league
+type baseline struct {
+	name     string
+	opponent Factory
+}
+
+// League pairs the learner against its current self, frozen snapshots of
+// its past selves and scripted baselines, to avoid cyclic strategies of pure
+// self-play
+type League struct {
+	learners  [3]mnk.Agent // The learning agent in either seat
+	snapshot  func() Snapshot
+	snapshots []Snapshot
+	size      int
+	every     uint
+	weights   Weights
+	baselines []baseline
+
+	// Current pairing
+	seat     int // Seat of the learner
+	opponent string
+
+	// Wins, draws and losses of the learner by opponent
+	results map[string]*[3]int
+
+	rng *rand.Rand
+}
+
+// NewLeague creates a league of the learners of both seats, keeping up to
+// size snapshots of their model, taken by snapshot every given number of
+// games
+func NewLeague(learners [3]mnk.Agent, snapshot func() Snapshot, size int, every uint,
+	weights Weights) (*League, error) {
+	if every == 0 || size < 1 {
+		return nil, fmt.Errorf("league: invalid snapshot interval %d or size %d",
+			every, size)
+	}
+
+	return &League{
+		learners: learners,
+		snapshot: snapshot,
+		size:     size,
+		every:    every,
+		weights:  weights,
+		results:  make(map[string]*[3]int),
+		rng:      mnk.NewRand(),
+	}, nil
+}
+
+// AddBaseline adds a scripted opponent under the given name
+func (l *League) AddBaseline(name string, opponent Factory) {
+	l.baselines = append(l.baselines, baseline{name, opponent})
+}
+
+// Snapshot freezes a copy of the current model as a future opponent,
+// replacing the oldest one once the league is full
+func (l *League) Snapshot() {
+	if len(l.snapshots) >= l.size {
+		l.snapshots = l.snapshots[1:]
+	}
+	l.snapshots = append(l.snapshots, l.snapshot())
+}
+
+// Pair returns the agents of the given game: the learner and a sampled
+// opponent, with the learner's color alternating between games
+func (l *League) Pair(game uint) (agents [3]mnk.Agent, err error) {
+	l.seat = 1 + int(game%2)
+	other := mnk.Opponent(l.seat)
+	agents[l.seat] = l.learners[l.seat]
+
+	// Kinds without opponents yet are left out
+	var w = l.weights
+	if len(l.snapshots) == 0 {
+		w.Past = 0
+	}
+	if len(l.baselines) == 0 {
+		w.Baseline = 0
+	}
+	if w.Self+w.Past+w.Baseline == 0 {
+		w.Self = 1
+	}
+
+	switch r := l.rng.Float64() * (w.Self + w.Past + w.Baseline); {
+	case r < w.Self:
+		l.opponent = "self"
+		agents[other] = l.learners[other]
+
+	case r < w.Self+w.Past:
+		s := l.snapshots[l.rng.Intn(len(l.snapshots))]
+		l.opponent = fmt.Sprintf("snapshot@%d", s.Iteration)
+		agents[other], err = s.Opponent(other)
+
+	default:
+		b := l.baselines[l.rng.Intn(len(l.baselines))]
+		l.opponent = b.name
+		agents[other], err = b.opponent(other)
+	}
+	return
+}
+
+// Record keeps the learner's result against the current opponent given the
+// winner of the game
+func (l *League) Record(winner int) {
+	r, ok := l.results[l.opponent]
+	if !ok {
+		r = new([3]int)
+		l.results[l.opponent] = r
+	}
+
+	switch winner {
+	case l.seat:
+		r[0]++
+	case 0:
+		r[1]++
+	default:
+		r[2]++
+	}
+}
+
+// PrintStandings writes the learner's results by opponent to w
+func (l *League) PrintStandings(w io.Writer) {
+	var opponents []string
+	for o := range l.results {
+		opponents = append(opponents, o)
+	}
+	sort.Strings(opponents)
+
+	fmt.Fprintln(w, "League results of the learner:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Opponent\tWins\tDraws\tLosses\t")
+	for _, o := range opponents {
+		r := l.results[o]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t\n", o, r[0], r[1], r[2])
+	}
+	tw.Flush()
+}
@@ -0,0 +1,64 @@
+package train
+
+import (
+	"testing"
+
+	"mnkagent/agent"
+	"mnkagent/mnk"
+)
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights("self=0.5, past=0.3,baseline=0")
+	if err != nil {
+		t.Fatalf("ParseWeights(): Unexpected error %v", err)
+	}
+	if w != (Weights{Self: 0.5, Past: 0.3}) {
+		t.Errorf("ParseWeights(): Expected self 0.5 and past 0.3, actual %+v", w)
+	}
+
+	for _, spec := range []string{"self", "self=-1", "others=1", "self=0,past=0"} {
+		if _, err := ParseWeights(spec); err == nil {
+			t.Errorf("ParseWeights(%q): Expected an error", spec)
+		}
+	}
+}
+
+func TestLeague(t *testing.T) {
+	learners := [3]mnk.Agent{nil, agent.NewRandomAgent(1, "X"), agent.NewRandomAgent(2, "O")}
+	var iteration uint
+	snapshot := func() Snapshot {
+		iteration++
+		return Snapshot{Iteration: iteration, Opponent: func(id int) (mnk.Agent, error) {
+			return agent.NewRandomAgent(id, "snapshot"), nil
+		}}
+	}
+
+	if _, err := NewLeague(learners, snapshot, 1, 0, Weights{Self: 1}); err == nil {
+		t.Error("NewLeague(): Expected an error for a zero snapshot interval")
+	}
+	l, err := NewLeague(learners, snapshot, 1, 10, Weights{Past: 1})
+	if err != nil {
+		t.Fatalf("NewLeague(): Unexpected error %v", err)
+	}
+
+	// Without snapshots the learner plays itself
+	agents, err := l.Pair(1)
+	if err != nil || agents[1] != learners[1] || agents[2] != learners[2] {
+		t.Errorf("Pair(): Expected self-play without snapshots, actual %v (%v)", agents, err)
+	}
+
+	l.Snapshot()
+	l.Snapshot()
+	if len(l.snapshots) != 1 || l.snapshots[0].Iteration != 2 {
+		t.Fatalf("Snapshot(): Expected the league to keep the latest snapshot, actual %d", len(l.snapshots))
+	}
+
+	agents, err = l.Pair(2)
+	if err != nil || agents[1] != learners[1] || agents[2].GetSign() != "snapshot" {
+		t.Errorf("Pair(): Expected the learner as X against the snapshot, actual %v (%v)", agents, err)
+	}
+	l.Record(1)
+	if r := l.results["snapshot@2"]; r == nil || r[0] != 1 {
+		t.Errorf("Record(): Expected a win against the snapshot, actual %v", l.results)
+	}
+}
@@ -0,0 +1,82 @@
+// Package train trains learning agents by playing rounds of m,n,k-games,
+// against themselves or a league of opponents, and evaluates them against
+// baselines
+package train
+
+import (
+	"mnkagent/game"
+	"mnkagent/mnk"
+)
+
+// Factory creates an agent seated as the given player
+type Factory func(id int) (mnk.Agent, error)
+
+// Session plays training games between the learners of both seats, or
+// between them and the opponents of a league
+type Session struct {
+	Env      mnk.Environment
+	Learners [3]mnk.Agent // The learning agent in either seat
+	League   *League      // Pairs the learners with opponents, if set
+
+	// Observer follows every game; it may be nil
+	Observer game.Observer
+
+	// Stop ends the session before the next game once closed
+	Stop <-chan struct{}
+
+	// Before is called with the number of every game, starting at 1, and
+	// the agents about to play it; After with its winner, zero for a draw.
+	// An error of After ends the session. Either may be nil.
+	Before func(c uint, agents [3]mnk.Agent)
+	After  func(c uint, winner int, agents [3]mnk.Agent) error
+}
+
+// Run plays up to rounds games, the winner of a game starting the next one,
+// and returns the games won by each player, draws first, and the number of
+// games played
+func (s *Session) Run(rounds uint) (log []int, played uint, err error) {
+	log = make([]int, 3)
+
+	for c, turn := uint(1), 1; c <= rounds; c++ {
+		select {
+		case <-s.Stop:
+			return
+		default:
+		}
+
+		var agents = s.Learners
+		if s.League != nil {
+			if agents, err = s.League.Pair(c); err != nil {
+				return
+			}
+		}
+		if s.Before != nil {
+			s.Before(c, agents)
+		}
+
+		// Start a new round and get the winner's id
+		pTurn := turn
+		if turn, err = game.Round(s.Env, agents, turn, s.Observer); err != nil {
+			return
+		}
+		played = c
+
+		log[turn]++ // Keep scores
+		if s.League != nil {
+			s.League.Record(turn)
+			if c%s.League.every == 0 {
+				s.League.Snapshot()
+			}
+		}
+		if s.After != nil {
+			if err = s.After(c, turn, agents); err != nil {
+				return
+			}
+		}
+
+		if turn == 0 { // If it was a draw, next player starts the game
+			turn = mnk.Opponent(pTurn)
+		}
+	}
+	return
+}
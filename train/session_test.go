@@ -0,0 +1,50 @@
+package train
+
+import (
+	"errors"
+	"testing"
+
+	"mnkagent/agent"
+	"mnkagent/mnk"
+)
+
+func TestSession(t *testing.T) {
+	env, _ := mnk.NewMNKBoard(3, 3, 3)
+	s := Session{
+		Env:      env,
+		Learners: [3]mnk.Agent{nil, agent.NewRandomAgent(1, "X"), agent.NewRandomAgent(2, "O")},
+	}
+
+	var games uint
+	s.After = func(c uint, winner int, agents [3]mnk.Agent) error {
+		if games++; c != games || agents != s.Learners {
+			t.Fatalf("After(): Expected game %d of the learners, actual %d", games, c)
+		}
+		return nil
+	}
+	log, played, err := s.Run(20)
+	if err != nil || played != 20 || log[0]+log[1]+log[2] != 20 {
+		t.Errorf("Run(): Expected 20 games, actual %d with %v (%v)", played, log, err)
+	}
+
+	// Stopping ends the session before the next game
+	stop := make(chan struct{})
+	s.Stop = stop
+	s.After = func(c uint, winner int, agents [3]mnk.Agent) error {
+		if c == 3 {
+			close(stop)
+		}
+		return nil
+	}
+	if _, played, err = s.Run(20); err != nil || played != 3 {
+		t.Errorf("Run(): Expected 3 games before stopping, actual %d (%v)", played, err)
+	}
+
+	// As does an error after a game
+	s.Stop = nil
+	failed := errors.New("failed")
+	s.After = func(uint, int, [3]mnk.Agent) error { return failed }
+	if _, played, err = s.Run(20); err != failed || played != 1 {
+		t.Errorf("Run(): Expected the error after the first game, actual %d (%v)", played, err)
+	}
+}